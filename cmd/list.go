@@ -7,11 +7,10 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 
-	"github.com/imxw/gitlab-scaffold/internal/config"
-	"github.com/imxw/gitlab-scaffold/internal/gitlabx"
 	"github.com/imxw/gitlab-scaffold/internal/scaffold"
 )
 
@@ -28,14 +27,17 @@ var listCmd = &cobra.Command{
 	Short: "List available scaffold templates",
 	Long:  `List available scaffold templates`,
 	Run: func(cmd *cobra.Command, args []string) {
-		url := config.C().GetGitlab().BaseURL
-		token := config.C().GetGitlab().Token
-		client, err := gitlabx.NewClient(url, token)
+		client, err := newClient()
 		if err != nil {
 			panic(err)
 		}
 
-		templates, err := scaffold.ListTemplates(client)
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		templates, err := scaffold.ListTemplates(ctx, client)
+		// 输出本次运行中重试过的请求
+		client.PrintRetrySummary(os.Stderr)
 		if err != nil {
 			panic(err)
 		}
@@ -0,0 +1,558 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/imxw/gitlab-scaffold/internal/gitlabx"
+	"github.com/imxw/gitlab-scaffold/internal/workflow"
+)
+
+// maskedValue 代替变量值输出，计划中不会读取或显示变量的值
+const maskedValue = "********"
+
+// projectPlan 描述 glfast use 将要在 GitLab 中执行的操作，用于 --dry-run
+type projectPlan struct {
+	// Groups 是使用 --create-group 时需要从上到下依次创建的组
+	Groups       []string
+	GroupOptions gitlabx.GroupOptions
+
+	Project         string
+	Description     string
+	Visibility      string
+	Topics          []string
+	InitReadme      bool
+	LFSEnabled      *bool
+	AvatarName      string
+	Avatar          []byte
+	Template        string
+	TemplateProject string
+	Variables       []gitlabx.Variable
+	Runners         []gitlabx.Runner
+	Files           map[string]*gitlabx.FileData
+	CommitMessage   string
+	InitialBranch   string
+	Branches        []string
+	DefaultBranch   string
+
+	// Settings 是从模板项目复制的设置
+	Settings gitlabx.ProjectSettings
+
+	ProtectedBranches []gitlabx.ProtectedBranch
+	ProtectedTags     []gitlabx.ProtectedTag
+	Merge             gitlabx.MergeSettings
+}
+
+// printPlan 输出计划，变量只显示名称、作用域和属性，值以掩码代替
+func printPlan(w io.Writer, plan *projectPlan) {
+	fmt.Fprintf(w, "\nDry run, nothing will be changed in GitLab.\n\n")
+
+	if len(plan.Groups) > 0 {
+		fmt.Fprintln(w, "Groups:")
+		visibility := plan.GroupOptions.Visibility
+		if visibility == "" {
+			visibility = "private"
+		}
+		for _, group := range plan.Groups {
+			fmt.Fprintf(w, "  create      %s (%s)\n", group, visibility)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "Project:")
+	fmt.Fprintf(w, "  create      %s\n", plan.Project)
+	fmt.Fprintf(w, "  description %s\n", plan.Description)
+	fmt.Fprintf(w, "  template    %s\n", plan.Template)
+	fmt.Fprintf(w, "  visibility  %s\n", plan.Visibility)
+	if len(plan.Topics) > 0 {
+		fmt.Fprintf(w, "  topics      %s\n", strings.Join(plan.Topics, ", "))
+	}
+	if plan.AvatarName != "" {
+		fmt.Fprintf(w, "  avatar      %s (%d B)\n", plan.AvatarName, len(plan.Avatar))
+	}
+	if plan.InitReadme {
+		fmt.Fprintln(w, "  readme      initialized by GitLab")
+	}
+	if plan.LFSEnabled != nil {
+		fmt.Fprintf(w, "  lfs         %t\n", *plan.LFSEnabled)
+	}
+
+	if plan.TemplateProject != "" {
+		fmt.Fprintf(w, "\nCI/CD variables copied from %s (%d):\n", plan.TemplateProject, len(plan.Variables))
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, v := range plan.Variables {
+			fmt.Fprintf(tw, "  %s\tscope=%s\tprotected=%t\tmasked=%t\tvalue=%s\n",
+				v.Key, v.EnvironmentScope, v.Protected, v.Masked, maskedValue)
+		}
+		tw.Flush()
+
+		fmt.Fprintf(w, "\nRunners enabled from %s (%d):\n", plan.TemplateProject, len(plan.Runners))
+		for _, r := range plan.Runners {
+			fmt.Fprintf(w, "  #%d %s\n", r.ID, r.Description)
+		}
+	}
+
+	paths := make([]string, 0, len(plan.Files))
+	for path := range plan.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fmt.Fprintf(w, "\nFiles committed to %s (%d):\n", plan.InitialBranch, len(paths))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, path := range paths {
+		file := plan.Files[path]
+		fmt.Fprintf(tw, "  %s\t%d B\t%s\n", path, file.Size(), file.Encoding)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "  message: %s\n", plan.CommitMessage)
+
+	fmt.Fprintln(w, "\nBranches:")
+	fmt.Fprintf(w, "  create project with default branch %s\n", plan.InitialBranch)
+	for _, branch := range plan.Branches {
+		fmt.Fprintf(w, "  create %s from %s\n", branch, plan.InitialBranch)
+	}
+	if plan.DefaultBranch != plan.InitialBranch {
+		fmt.Fprintf(w, "  set default branch to %s\n", plan.DefaultBranch)
+	}
+
+	if len(plan.Settings) > 0 {
+		fields := make([]string, 0, len(plan.Settings))
+		for name := range plan.Settings {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+
+		fmt.Fprintf(w, "\nSettings copied from %s:\n", plan.TemplateProject)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, name := range fields {
+			fmt.Fprintf(tw, "  %s\t%v\n", name, plan.Settings[name])
+		}
+		tw.Flush()
+	}
+
+	if len(plan.ProtectedBranches) > 0 {
+		fmt.Fprintln(w, "\nProtected branches:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, b := range plan.ProtectedBranches {
+			fmt.Fprintf(tw, "  %s\tpush=%s\tmerge=%s\tforce push=%t\tcode owner approval=%t\n",
+				b.Name, orDefault(b.PushAccessLevel), orDefault(b.MergeAccessLevel), b.AllowForcePush, b.CodeOwnerApprovalRequired)
+		}
+		tw.Flush()
+	}
+
+	if len(plan.ProtectedTags) > 0 {
+		fmt.Fprintln(w, "\nProtected tags:")
+		for _, t := range plan.ProtectedTags {
+			fmt.Fprintf(w, "  %s  create=%s\n", t.Name, orDefault(t.CreateAccessLevel))
+		}
+	}
+
+	if !plan.Merge.IsZero() {
+		m := plan.Merge
+		fmt.Fprintln(w, "\nMerge settings:")
+		if m.Method != "" {
+			fmt.Fprintf(w, "  merge method            %s\n", m.Method)
+		}
+		if m.SquashOption != "" {
+			fmt.Fprintf(w, "  squash option           %s\n", m.SquashOption)
+		}
+		if m.PipelinesMustSucceed != nil {
+			fmt.Fprintf(w, "  pipelines must succeed  %t\n", *m.PipelinesMustSucceed)
+		}
+		if m.RemoveSourceBranch != nil {
+			fmt.Fprintf(w, "  remove source branch    %t\n", *m.RemoveSourceBranch)
+		}
+	}
+}
+
+func orDefault(s string) string {
+	if s == "" {
+		return "default"
+	}
+	return s
+}
+
+// newProjectPlan 根据渲染结果和项目设置生成计划。
+// 设置了 copy_settings 或 copy_protection 时会读取模板项目的设置、受保护分支、受保护标签和合并设置。
+func newProjectPlan(ctx context.Context, client *gitlabx.Client, project, templateProject string, tmpl *renderedTemplate) (*projectPlan, error) {
+	// 所有设置合并之后再确定默认分支
+	settings := tmpl.Project
+	settings.Branches = settings.Branches.Resolve()
+	if err := settings.Check(); err != nil {
+		return nil, err
+	}
+
+	branches := settings.Branches
+	message, err := branches.Message(tmpl.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	visibility := settings.Visibility
+	if visibility == "" {
+		visibility = "private"
+	}
+
+	plan := &projectPlan{
+		Project:         project,
+		Description:     description,
+		Visibility:      visibility,
+		Topics:          settings.Topics,
+		InitReadme:      settings.InitializeWithReadme != nil && *settings.InitializeWithReadme,
+		LFSEnabled:      settings.LFSEnabled,
+		Template:        tmpl.Info.String(),
+		TemplateProject: templateProject,
+		Files:           tmpl.Files,
+		CommitMessage:   message,
+		InitialBranch:   branches.Initial,
+		Branches:        branches.Extra,
+		DefaultBranch:   branches.Default,
+
+		ProtectedBranches: settings.ProtectedBranches,
+		ProtectedTags:     settings.ProtectedTags,
+		Merge:             settings.Merge,
+	}
+
+	// 头像从渲染后的模板文件中读取
+	if settings.Avatar != "" {
+		file, ok := tmpl.Files["/"+strings.TrimPrefix(settings.Avatar, "/")]
+		if !ok {
+			return nil, fmt.Errorf("avatar %s not found in the template", settings.Avatar)
+		}
+		plan.AvatarName = path.Base(settings.Avatar)
+		if plan.Avatar, err = file.Decode(); err != nil {
+			return nil, err
+		}
+	}
+
+	if templateProject != "" && settings.CopySettings.IsEnabled() {
+		fields := settings.CopySettings.Fields
+		if len(fields) == 0 {
+			fields = gitlabx.ProjectSettingFields()
+		}
+		if plan.Settings, err = client.GetProjectSettings(ctx, templateProject, fields); err != nil {
+			return nil, err
+		}
+	}
+
+	if templateProject != "" && settings.CopyProtection != nil && *settings.CopyProtection {
+		if err := plan.copyProtection(ctx, client); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+// copyProtection 把模板项目的保护设置合并到计划中，已声明的同名分支、标签和合并选项优先
+func (plan *projectPlan) copyProtection(ctx context.Context, client *gitlabx.Client) error {
+	branches, err := client.ListProtectedBranches(ctx, plan.TemplateProject)
+	if err != nil {
+		return err
+	}
+	for _, b := range branches {
+		if !hasProtectedBranch(plan.ProtectedBranches, b.Name) {
+			plan.ProtectedBranches = append(plan.ProtectedBranches, b)
+		}
+	}
+
+	tags, err := client.ListProtectedTags(ctx, plan.TemplateProject)
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		if !hasProtectedTag(plan.ProtectedTags, t.Name) {
+			plan.ProtectedTags = append(plan.ProtectedTags, t)
+		}
+	}
+
+	merge, err := client.GetMergeSettings(ctx, plan.TemplateProject)
+	if err != nil {
+		return err
+	}
+	plan.Merge = merge.Override(plan.Merge)
+	return nil
+}
+
+func hasProtectedBranch(branches []gitlabx.ProtectedBranch, name string) bool {
+	for _, b := range branches {
+		if b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasProtectedTag(tags []gitlabx.ProtectedTag, name string) bool {
+	for _, t := range tags {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// inspect 通过只读的 API 调用补全计划：读取模板项目的变量和 Runner
+func (plan *projectPlan) inspect(ctx context.Context, client *gitlabx.Client) error {
+	var err error
+	if plan.TemplateProject != "" {
+		if plan.Variables, err = client.ListProjectVariables(ctx, plan.TemplateProject); err != nil {
+			return err
+		}
+		if plan.Runners, err = client.ListProjectRunners(ctx, plan.TemplateProject); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// steps 把计划转换为可撤销的步骤。提交无法单独撤销，会随项目一起删除。
+func (plan *projectPlan) steps(client *gitlabx.Client) []workflow.Step {
+	project := plan.Project
+
+	// 从上到下创建缺少的组，撤销时按相反顺序删除
+	var steps []workflow.Step
+	for _, group := range plan.Groups {
+		group := group
+		steps = append(steps, workflow.Step{
+			Name: "create group " + group,
+			Do: func(ctx context.Context) error {
+				_, err := client.CreateGroup(ctx, group, plan.GroupOptions)
+				return err
+			},
+			Undo: func(ctx context.Context) error {
+				return client.DeleteGroup(ctx, group)
+			},
+			Check: func(ctx context.Context) (bool, error) {
+				_, err := client.GetNamespace(ctx, group)
+				if errors.Is(err, gitlabx.ErrNotFound) {
+					return false, nil
+				}
+				return err == nil, err
+			},
+		})
+	}
+
+	steps = append(steps, workflow.Step{
+		Name: "create project " + project,
+		Do: func(ctx context.Context) error {
+			return client.CreateProjectInGroup(ctx, projectName, groupName, gitlabx.ProjectOptions{
+				Description:          plan.Description,
+				DefaultBranch:        plan.InitialBranch,
+				Visibility:           plan.Visibility,
+				Topics:               plan.Topics,
+				InitializeWithReadme: plan.InitReadme,
+				LFSEnabled:           plan.LFSEnabled,
+			})
+		},
+		Undo: func(ctx context.Context) error {
+			return client.DeleteProject(ctx, project)
+		},
+		Check: func(ctx context.Context) (bool, error) {
+			return client.IsProjectExist(ctx, project)
+		},
+	})
+
+	if plan.TemplateProject != "" {
+		var copied []gitlabx.Variable
+		var enabled []int
+		steps = append(steps, workflow.Step{
+			Name: "copy CI/CD variables from " + plan.TemplateProject,
+			Do: func(ctx context.Context) (err error) {
+				copied, err = client.CopyProjectVariables(ctx, plan.TemplateProject, project)
+				return err
+			},
+			Undo: func(ctx context.Context) error {
+				var errs []error
+				for _, v := range copied {
+					errs = append(errs, client.DeleteProjectVariable(ctx, project, v))
+				}
+				return errors.Join(errs...)
+			},
+			Check: func(ctx context.Context) (bool, error) {
+				return hasVariables(ctx, client, plan.TemplateProject, project)
+			},
+			// 部分变量复制失败时删除已经复制的变量
+			Partial: true,
+		}, workflow.Step{
+			Name: "enable runners from " + plan.TemplateProject,
+			Do: func(ctx context.Context) (err error) {
+				enabled, err = client.EnableRunner(ctx, plan.TemplateProject, project)
+				return err
+			},
+			Undo: func(ctx context.Context) error {
+				var errs []error
+				for _, id := range enabled {
+					errs = append(errs, client.DisableRunner(ctx, project, id))
+				}
+				return errors.Join(errs...)
+			},
+			Check: func(ctx context.Context) (bool, error) {
+				return hasRunners(ctx, client, plan.TemplateProject, project)
+			},
+			// 部分 Runner 启用失败时停用已经启用的 Runner
+			Partial: true,
+		})
+	}
+
+	steps = append(steps, workflow.Step{
+		Name: "commit template files to " + plan.InitialBranch,
+		Do: func(ctx context.Context) error {
+			return client.CreateCommitFromFiles(ctx, project, plan.InitialBranch, plan.CommitMessage, plan.Files)
+		},
+		Check: func(ctx context.Context) (bool, error) {
+			return client.HasCommit(ctx, project, plan.InitialBranch, plan.CommitMessage)
+		},
+	})
+
+	// 头像随项目一起删除，无需单独撤销
+	if plan.AvatarName != "" {
+		steps = append(steps, workflow.Step{
+			Name: "upload avatar " + plan.AvatarName,
+			Do: func(ctx context.Context) error {
+				return client.UploadAvatar(ctx, project, plan.AvatarName, plan.Avatar)
+			},
+		})
+	}
+
+	for _, branch := range plan.Branches {
+		branch := branch
+		steps = append(steps, workflow.Step{
+			Name: fmt.Sprintf("create branch %s from %s", branch, plan.InitialBranch),
+			Do: func(ctx context.Context) error {
+				return client.CreateBranch(ctx, project, branch, plan.InitialBranch)
+			},
+			Undo: func(ctx context.Context) error {
+				return client.DeleteBranch(ctx, project, branch)
+			},
+			Check: func(ctx context.Context) (bool, error) {
+				return client.BranchExists(ctx, project, branch)
+			},
+		})
+	}
+
+	// 项目创建时已将初始分支设为默认分支
+	if plan.DefaultBranch != plan.InitialBranch {
+		steps = append(steps, workflow.Step{
+			Name: "set default branch to " + plan.DefaultBranch,
+			Do: func(ctx context.Context) error {
+				return client.SetDefaultBranch(ctx, project, plan.DefaultBranch)
+			},
+			Undo: func(ctx context.Context) error {
+				return client.SetDefaultBranch(ctx, project, plan.InitialBranch)
+			},
+			Check: func(ctx context.Context) (bool, error) {
+				branch, err := client.GetDefaultBranch(ctx, project)
+				return branch == plan.DefaultBranch, err
+			},
+		})
+	}
+
+	// 复制的设置随项目一起删除，无需单独撤销。合并设置在其后应用，优先于复制的设置。
+	if len(plan.Settings) > 0 {
+		steps = append(steps, workflow.Step{
+			Name: "copy project settings from " + plan.TemplateProject,
+			Do: func(ctx context.Context) error {
+				return client.EditProjectSettings(ctx, project, plan.Settings)
+			},
+		})
+	}
+
+	// 分支创建之后再应用保护设置，否则受保护的分支可能无法创建
+	for _, b := range plan.ProtectedBranches {
+		b := b
+		steps = append(steps, workflow.Step{
+			Name: "protect branch " + b.Name,
+			Do: func(ctx context.Context) error {
+				return client.ProtectBranch(ctx, project, b)
+			},
+			Undo: func(ctx context.Context) error {
+				return client.UnprotectBranch(ctx, project, b.Name)
+			},
+		})
+	}
+
+	for _, t := range plan.ProtectedTags {
+		t := t
+		steps = append(steps, workflow.Step{
+			Name: "protect tag " + t.Name,
+			Do: func(ctx context.Context) error {
+				return client.ProtectTag(ctx, project, t)
+			},
+			Undo: func(ctx context.Context) error {
+				return client.UnprotectTag(ctx, project, t.Name)
+			},
+		})
+	}
+
+	// 合并设置随项目一起删除，无需单独撤销
+	if !plan.Merge.IsZero() {
+		steps = append(steps, workflow.Step{
+			Name: "apply merge settings",
+			Do: func(ctx context.Context) error {
+				return client.SetMergeSettings(ctx, project, plan.Merge)
+			},
+		})
+	}
+
+	return steps
+}
+
+// hasVariables 判断模板项目的所有变量是否都已存在于目标项目中
+func hasVariables(ctx context.Context, client *gitlabx.Client, templateProject, project string) (bool, error) {
+	want, err := client.ListProjectVariables(ctx, templateProject)
+	if err != nil {
+		return false, err
+	}
+	got, err := client.ListProjectVariables(ctx, project)
+	if err != nil {
+		return false, err
+	}
+
+	exist := make(map[string]bool, len(got))
+	for _, v := range got {
+		exist[v.Key+"@"+v.EnvironmentScope] = true
+	}
+	for _, v := range want {
+		if !exist[v.Key+"@"+v.EnvironmentScope] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// hasRunners 判断模板项目的所有非共享 Runner 是否都已为目标项目启用
+func hasRunners(ctx context.Context, client *gitlabx.Client, templateProject, project string) (bool, error) {
+	want, err := client.ListProjectRunners(ctx, templateProject)
+	if err != nil {
+		return false, err
+	}
+	got, err := client.ListProjectRunners(ctx, project)
+	if err != nil {
+		return false, err
+	}
+
+	exist := make(map[int]bool, len(got))
+	for _, r := range got {
+		exist[r.ID] = true
+	}
+	for _, r := range want {
+		if !exist[r.ID] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
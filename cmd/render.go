@@ -0,0 +1,106 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/imxw/gitlab-scaffold/internal/gitlabx"
+	"github.com/imxw/gitlab-scaffold/internal/prompt"
+	"github.com/imxw/gitlab-scaffold/internal/scaffold"
+)
+
+var outputDir string
+var force bool
+
+// renderCmd represents the render command
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render a scaffold template to a local directory.",
+	Long: `Render a scaffold template to a local directory without creating anything in GitLab.
+
+The template is downloaded, unpacked and rendered exactly as 'glfast use' does, then the files are
+written to the output directory with their file modes preserved. This is useful to review a template,
+to push the result to another forge, or to check a template in CI.
+
+The project name defaults to the name of the output directory. The command refuses to write into a
+non-empty directory unless --force is given.
+	`,
+	Example: `  glfast render backend-java-service -o ./out -n tope-test --set package=com.example.tope
+  glfast render backend-java-service@v1.4.0 -o ./out -f values.yaml
+  glfast render --from-dir ./my-template -o ./out --force`,
+	Args: cobra.RangeArgs(0, 1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		source, err := templateSource(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		empty, err := scaffold.IsEmptyDir(outputDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !empty && !force {
+			log.Fatalf("output directory %s is not empty, use --force to write into it", outputDir)
+		}
+
+		if projectName == "" {
+			abs, err := filepath.Abs(outputDir)
+			if err != nil {
+				log.Fatal(err)
+			}
+			projectName = filepath.Base(abs)
+		}
+
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		// 本地模板目录不需要访问 GitLab
+		var client *gitlabx.Client
+		if !source.IsLocal() {
+			client, err = newClient()
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		var p *prompt.Prompter
+		if !noInput && prompt.IsTerminal(os.Stdin) {
+			p = prompt.New(ctx, os.Stdin, os.Stdout)
+		}
+
+		tmpl, err := renderTemplate(ctx, client, source, p)
+		// 输出本次运行中重试过的请求
+		client.PrintRetrySummary(os.Stderr)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := scaffold.WriteFiles(outputDir, tmpl.Files); err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("Rendered %d files to %s\n", len(tmpl.Files), outputDir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	renderCmd.Flags().StringVarP(&outputDir, "output", "o", "", "directory to write the rendered files to")
+	renderCmd.Flags().StringVarP(&projectName, "name", "n", "", "name of the project (default is the name of the output directory)")
+	renderCmd.Flags().IntVarP(&port, "port", "p", -1, "port for the application (optional)")
+	renderCmd.Flags().BoolVar(&force, "force", false, "write into a non-empty output directory, overwriting existing files")
+	addTemplateFlags(renderCmd)
+
+	renderCmd.MarkFlagRequired("output")
+}
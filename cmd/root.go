@@ -6,12 +6,22 @@ license that can be found in the LICENSE file.
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/imxw/gitlab-scaffold/internal/config"
+	"github.com/imxw/gitlab-scaffold/internal/gitlabx"
 )
 
 var cfgFile string
+var timeout time.Duration
+var operationTimeout time.Duration
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -33,6 +43,8 @@ func Execute() {
 func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./config.yaml)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "time limit for the whole command, e.g. 10m (default gitlab.timeout, no limit)")
+	rootCmd.PersistentFlags().DurationVar(&operationTimeout, "operation-timeout", 0, "time limit for each GitLab operation, including retries (default gitlab.operation_timeout, 5m)")
 
 	// rootCmd.PersistentFlags().String("baseurl", "https://gitlab.com", "base URL for the GitLab instance")
 	// rootCmd.PersistentFlags().String("token", "", "token for GitLab")
@@ -41,3 +53,51 @@ func init() {
 	// viper.BindPFlag("gitlab.token", rootCmd.PersistentFlags().Lookup("token"))
 
 }
+
+// gitlabConfig 返回配置文件中的 gitlab 部分，命令行中指定的超时优先
+func gitlabConfig() gitlabx.Config {
+	cfg := config.C().GetGitlab()
+	flags := rootCmd.PersistentFlags()
+	if flags.Changed("timeout") {
+		cfg.Timeout = timeout
+	}
+	if flags.Changed("operation-timeout") {
+		cfg.OperationTimeout = operationTimeout
+	}
+	return cfg
+}
+
+// newClient 根据配置创建 GitLab 客户端
+func newClient() (*gitlabx.Client, error) {
+	return gitlabx.NewClientFromConfig(gitlabConfig())
+}
+
+// commandContext 返回整个命令共用的 context，超过 --timeout 或收到 SIGINT、SIGTERM 时取消，
+// 下载、渲染模板和询问输入时同样可以中断，临时目录等由各自的清理逻辑删除
+func commandContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if t := gitlabConfig().Timeout; t > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), t)
+	}
+	sigCtx, stop := withSignals(ctx)
+	// 先取消父 context，正常结束时不会被当作收到了信号
+	return sigCtx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// withSignals 返回收到 SIGINT 或 SIGTERM 时取消的 context，正在进行的请求会被中止。
+// 再次收到信号时恢复默认行为，直接退出而不等待清理完成。
+func withSignals(ctx context.Context) (context.Context, context.CancelFunc) {
+	sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCtx.Done()
+		stop()
+		// 父 context 未结束说明是收到了信号，而不是超时或命令正常结束
+		if ctx.Err() == nil {
+			fmt.Fprintln(os.Stderr, "\nInterrupted, cleaning up (press Ctrl-C again to exit immediately)")
+		}
+	}()
+	return sigCtx, stop
+}
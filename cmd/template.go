@@ -0,0 +1,131 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/imxw/gitlab-scaffold/internal/config"
+	"github.com/imxw/gitlab-scaffold/internal/gitlabx"
+	"github.com/imxw/gitlab-scaffold/internal/prompt"
+	"github.com/imxw/gitlab-scaffold/internal/scaffold"
+	"github.com/imxw/gitlab-scaffold/internal/values"
+)
+
+var valueOpts values.Options
+var noInput bool
+var templateRef string
+var fromDir string
+
+// renderedTemplate 是模板渲染的结果
+type renderedTemplate struct {
+	Info  scaffold.TemplateInfo
+	Vars  map[string]interface{}
+	Files map[string]*gitlabx.FileData
+	Data  scaffold.TemplateData
+	// Project 是配置文件与模板清单合并后的项目设置
+	Project scaffold.ProjectConfig
+}
+
+// addTemplateFlags 为渲染模板的命令添加共用的参数
+func addTemplateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&valueOpts.ValueFiles, "values", "f", nil, "template variables from a YAML file (can be repeated)")
+	cmd.Flags().StringArrayVar(&valueOpts.Values, "set", nil, "set template variables on the command line (e.g. --set db.type=mysql,tags={a,b})")
+	cmd.Flags().StringArrayVar(&valueOpts.FileValues, "set-file", nil, "set a template variable from the contents of a file (e.g. --set-file license=./LICENSE)")
+	cmd.Flags().StringVar(&templateRef, "ref", "", "template version to use: a tag, branch or commit SHA (default branch if empty)")
+	cmd.Flags().StringVar(&fromDir, "from-dir", "", "render the template from a local directory instead of downloading it (for template development)")
+	cmd.Flags().BoolVar(&noInput, "no-input", false, "never prompt for missing inputs, fail instead (for CI)")
+}
+
+// renderTemplate 下载模板（或使用本地模板目录），读取模板清单，合并并校验变量，
+// 然后渲染出待提交的文件。p 不为 nil 时会询问缺少或不合法的变量。
+// 模板的临时目录在返回前删除。
+func renderTemplate(ctx context.Context, client *gitlabx.Client, source scaffold.Source, p *prompt.Prompter) (*renderedTemplate, error) {
+	// 下载模板压缩包到本地，或者直接使用本地模板目录
+	rootPath, info, cleanup, err := source.Fetch(ctx, client)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Using template %s\n", info.Location())
+
+	// 读取模板清单并校验变量
+	manifest, err := scaffold.LoadManifest(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	supplied, err := valueOpts.MergeValues()
+	if err != nil {
+		return nil, err
+	}
+
+	if p != nil {
+		if err := askTemplateVariables(p, manifest, supplied); err != nil {
+			return nil, err
+		}
+	}
+
+	vars, err := manifest.Resolve(supplied)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template variables:\n%v", err)
+	}
+
+	data := scaffold.TemplateData{
+		Name: projectName,
+		Port: port,
+		Vars: vars,
+
+		Template: info,
+	}
+
+	renderer, err := scaffold.NewRenderer(config.C().GetTemplate(), manifest, rootPath, data)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := renderer.Render()
+	if err != nil {
+		return nil, fmt.Errorf("error walking the path %v: %v", rootPath, err)
+	}
+	// 渲染期间收到信号时不再继续
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &renderedTemplate{
+		Info:    info,
+		Vars:    vars,
+		Files:   files,
+		Data:    data,
+		Project: config.C().GetProject().Override(manifest.Project),
+	}, nil
+}
+
+// templateSource 根据命令行参数和 --ref、--from-dir 确定模板来源
+func templateSource(args []string) (scaffold.Source, error) {
+	var source scaffold.Source
+	if len(args) > 0 {
+		source.Name, source.Ref = scaffold.ParseTemplateRef(args[0])
+	}
+
+	if source.Ref != "" && templateRef != "" && source.Ref != templateRef {
+		return source, fmt.Errorf("conflicting template versions: %s@%s and --ref %s", source.Name, source.Ref, templateRef)
+	}
+	if source.Ref == "" {
+		source.Ref = templateRef
+	}
+
+	source.Dir = fromDir
+	if source.Name == "" && !source.IsLocal() {
+		return source, fmt.Errorf("a template name or --from-dir is required")
+	}
+
+	return source, nil
+}
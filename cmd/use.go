@@ -7,22 +7,32 @@ package cmd
 
 import (
 	"fmt"
-	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/imxw/gitlab-scaffold/internal/config"
-	"github.com/imxw/gitlab-scaffold/internal/gitlabx"
+	"github.com/imxw/gitlab-scaffold/internal/prompt"
 	"github.com/imxw/gitlab-scaffold/internal/scaffold"
+	"github.com/imxw/gitlab-scaffold/internal/workflow"
 )
 
 var projectName string
 var port int
 var groupName string
 var description string
+var dryRun bool
+var keepOnFailure bool
+var resume bool
+var visibility string
+var topics []string
+var avatarFile string
+var initReadme bool
+var lfsEnabled bool
+var createGroup bool
 
 // useCmd represents the use command
 var useCmd = &cobra.Command{
@@ -33,107 +43,197 @@ var useCmd = &cobra.Command{
 The command uses the format: 'scaffold use TEMPLATE_NAME -n PROJECT_NAME -p PORT -g GROUP_NAME'. 
 This command creates a new project using the specified scaffold template and names the project as PROJECT_NAME. For backend applications, the command assigns the specified PORT.
 Additionally, the new project is associated with the given GROUP_NAME. If the project is frontend-based, specifying a port is not necessary.
+
+Template variables are exposed as .Vars and merged in this order, later sources overriding earlier ones:
+defaults from the template's scaffold.yaml, --values files (in the given order), --set, --set-file.
+
+A template version can be pinned with TEMPLATE_NAME@REF or --ref REF, where REF is a tag, branch or commit SHA.
+The resolved commit SHA is recorded in the default message of the project's initial commit.
+
+While developing a template, --from-dir DIR renders it from a local directory instead of the template group.
+TEMPLATE_NAME is then optional; when given, CI variables and runners are still copied from that template project.
+
+When run in a terminal, missing inputs (name, group, port and template variables) are asked for
+interactively and a summary is shown for confirmation before anything is created in GitLab.
+Use --no-input to disable prompting, e.g. in CI.
+
+With --dry-run the template is rendered and the plan is printed: the project to create, the CI/CD variables
+(keys and scopes only, values masked) and runners to copy, every file with its size and encoding, and the
+branch operations. Only read-only API calls are made, to check that the group exists and the name is free.
+
+Creating the project runs as a sequence of steps. If a step fails or times out, or the command receives SIGINT
+or SIGTERM, the request in flight is aborted, the completed steps are undone in reverse order and the new
+project is deleted. A second SIGINT exits immediately without cleaning up. Use --keep-on-failure
+to leave the project in place for debugging.
+
+Progress is recorded in a local journal per target project. If a run dies halfway, run the same command again
+with --resume: steps already done in GitLab (project, variables, runners, initial commit, branches) are
+detected and skipped, and only the missing ones are performed. The template version of the interrupted run
+is reused unless another one is given. --resume fails if no journal is recorded for the target project, or if
+the journal was started with a different template.
+
+Visibility, topics, avatar, README initialization and LFS default to the 'project' section of the template's
+scaffold.yaml, then of the config file. The flags below override both; --avatar uploads a local image, while
+'avatar' in scaffold.yaml names an image inside the template.
+
+With --create-group, missing groups in the --group path are created one level at a time under their parent,
+using the visibility and description from the 'group' section of the config file. The run stops if the token
+may not create a group at some level, and groups created by a failed run are deleted with the project.
 	`,
-	Example: "  `scaffold use backend-java-service -n tope-test -p 8955 -g team1/backend`",
-	Args:    cobra.ExactArgs(1),
+	Example: `  scaffold use backend-java-service -n tope-test -p 8955 -g team1/backend
+  scaffold use backend-java-service -n tope-test -g team1/backend --set package=com.example.tope,db.type=mysql
+  scaffold use backend-java-service -n tope-test -g team1/backend -f values.yaml --set-file license=./LICENSE
+  scaffold use backend-java-service@v1.4.0 -n tope-test -g team1/backend
+  scaffold use --from-dir ./my-template -n tope-test -g team1/backend
+  scaffold use backend-java-service -n tope-test -g team1/backend --dry-run
+  scaffold use backend-java-service -n tope-test -g team1/backend --resume
+  scaffold use backend-java-service -n tope-test -g team1/backend --visibility internal --topics backend,java
+  scaffold use backend-java-service -n tope-test -g team1/backend/payments --create-group`,
+	Args: cobra.RangeArgs(0, 1),
 	Run: func(cmd *cobra.Command, args []string) {
 
-		// 获取模板名称
-		templateName := args[0]
-
-		url := config.C().GetGitlab().BaseURL
-		token := config.C().GetGitlab().Token
-		client, err := gitlabx.NewClient(url, token)
+		// 获取模板名称和版本
+		source, err := templateSource(args)
 		if err != nil {
-			panic(err)
+			log.Fatal(err)
 		}
 
-		// 判断gitlab项目是否存在
-		nameWithNamespace := groupName + "/" + projectName
+		// 在终端中运行且未指定 --no-input 时，缺少的参数通过交互方式询问
+		interactive := !noInput && prompt.IsTerminal(os.Stdin)
+		if !interactive {
+			var missing []string
+			if projectName == "" {
+				missing = append(missing, `"name"`)
+			}
+			if groupName == "" {
+				missing = append(missing, `"group"`)
+			}
+			if len(missing) > 0 {
+				log.Fatalf("required flag(s) %s not set", strings.Join(missing, ", "))
+			}
+		}
 
-		exist, err := client.IsProjectExist(nameWithNamespace)
+		client, err := newClient()
 		if err != nil {
 			panic(err)
 		}
 
-		if exist {
-			log.Fatalf("%s already exists, please use a different project name.", projectName)
+		// 收到 SIGINT 或 SIGTERM 时中止正在执行的操作，创建项目时还会撤销已完成的步骤
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		var p *prompt.Prompter
+		if interactive {
+			p = prompt.New(ctx, os.Stdin, os.Stdout)
+			if err := askProjectInputs(ctx, p, client, cmd.Flags().Changed("port")); err != nil {
+				log.Fatal(err)
+			}
 		}
-		// 创建项目
+
 		if description == "" {
 			description = projectName
 		}
-		err = client.CreateProjectInGroup(projectName, groupName, description)
-		if err != nil {
-			log.Fatal(err)
-		}
-		if err := client.CopyProjectVariables(scaffold.DefaultTemplateGroup+"/"+templateName, nameWithNamespace); err != nil {
-			log.Fatal(err)
-		}
 
-		if err := client.EnableRunner(scaffold.DefaultTemplateGroup+"/"+templateName, nameWithNamespace); err != nil {
-			log.Fatal(err)
-		}
+		nameWithNamespace := groupName + "/" + projectName
 
-		// 下载模板压缩包到本地
-		rootPath, err := scaffold.DownloadAndUnpackTemplateToTempDir(client, templateName)
+		// 读取上次运行留下的操作日志，继续执行时使用相同的模板版本
+		journal, unfinished, err := workflow.OpenJournal(nameWithNamespace)
 		if err != nil {
 			log.Fatal(err)
 		}
-
-		// 检查本地文件夹是否存在
-		if _, err := os.Stat(rootPath); os.IsNotExist(err) {
-			log.Fatalf("Local folder '%s' does not exist", rootPath)
+		if resume {
+			if source.Ref, err = resumeRef(nameWithNamespace, journal, unfinished, source); err != nil {
+				log.Fatal(err)
+			}
 		}
 
 		// 渲染模板并修改文件及文件夹名
-		fileMap := make(map[string]*gitlabx.FileData)
-
-		data := scaffold.TemplateData{
-			Name: projectName,
-			Port: port,
+		tmpl, err := renderTemplate(ctx, client, source, p)
+		if err != nil {
+			log.Fatal(err)
 		}
+		templateInfo := tmpl.Info
 
-		err = filepath.Walk(rootPath, func(path string, info fs.FileInfo, err error) error {
-			visitFileMap, err := scaffold.VisitAndModifyFiles(rootPath, path, data, info, err)
+		// 交互模式下在调用 GitLab 创建任何资源之前请用户确认
+		if interactive && !dryRun {
+			printSummary(os.Stdout, templateInfo, tmpl.Vars)
+			ok, err := p.Confirm("Proceed?", true)
 			if err != nil {
-				fmt.Printf("error visiting and modifying files in %v: %v\n", path, err)
-				return err
+				log.Fatal(err)
 			}
-
-			for k, v := range visitFileMap {
-				fileMap[k] = v
+			if !ok {
+				log.Fatal(prompt.ErrAborted)
 			}
+		}
 
-			return nil
-		})
-
+		// 判断gitlab项目是否存在，继续执行时由各步骤自行检查
+		exist, err := client.IsProjectExist(ctx, nameWithNamespace)
 		if err != nil {
-			fmt.Printf("error walking the path %v: %v\n", rootPath, err)
-			// 在此删除项目
-			delErr := client.DeleteProject(nameWithNamespace)
-			if delErr != nil {
-				fmt.Println("Error in DeleteProject: ", delErr)
-			} else {
-				fmt.Println("Project deleted due to error in filepath.Walk")
+			log.Fatal(err)
+		}
+
+		if exist && !resume {
+			if unfinished {
+				log.Fatalf("%s was partially created by a previous run, use --resume to finish it.", nameWithNamespace)
 			}
-			return
+			log.Fatalf("%s already exists, please use a different project name.", projectName)
 		}
 
-		// 提交commit
-		if err := client.CreateCommitFromFiles(nameWithNamespace, fileMap); err != nil {
+		// 按完整路径确认组或用户命名空间存在且可以访问，
+		// 使用 --create-group 时找出路径中缺少的组，在创建项目之前逐级创建
+		var missingGroups []string
+		if createGroup {
+			_, missingGroups, err = client.MissingGroups(ctx, groupName)
+		} else {
+			_, err = client.GetNamespace(ctx, groupName)
+		}
+		if err != nil {
 			log.Fatal(err)
 		}
 
-		// 创建dev分支
-		devBranch := "dev"
-		mainbranch := "master"
+		// 只给出本地模板目录时，没有可以复制 CI 变量和 Runner 的模板项目
+		templateProject := ""
+		if source.Name != "" {
+			templateProject = scaffold.DefaultTemplateGroup + "/" + source.Name
+		}
+
+		// 命令行参数优先于模板清单和配置文件中的项目设置
+		tmpl.Project = tmpl.Project.Override(projectFlags(cmd))
 
-		if err := client.CreateBranch(nameWithNamespace, devBranch, mainbranch); err != nil {
+		// 按分支策略生成计划，提交信息中默认记录模板版本
+		plan, err := newProjectPlan(ctx, client, nameWithNamespace, templateProject, tmpl)
+		if err != nil {
 			log.Fatal(err)
 		}
+		plan.Groups = missingGroups
+		plan.GroupOptions = config.C().GetGroup()
+		if avatarFile != "" {
+			if plan.Avatar, err = os.ReadFile(avatarFile); err != nil {
+				log.Fatal(err)
+			}
+			plan.AvatarName = filepath.Base(avatarFile)
+		}
+
+		if dryRun {
+			if err := plan.inspect(ctx, client); err != nil {
+				log.Fatal(err)
+			}
+			printPlan(os.Stdout, plan)
+			return
+		}
 
-		if err := client.SetDefaultBranch(nameWithNamespace, devBranch); err != nil {
+		// 创建项目，复制 CI 变量和 Runner，提交模板文件，按分支策略创建分支并设置默认分支
+		wf := workflow.New(os.Stdout)
+		wf.KeepOnFailure = keepOnFailure
+		wf.Resume = resume
+		wf.Journal = journal
+		journal.Template = templateInfo.Name
+		journal.SHA = templateInfo.SHA
+		wf.Add(plan.steps(client)...)
+		err = wf.Run(ctx)
+		// 输出本次运行中重试过的请求
+		client.PrintRetrySummary(os.Stderr)
+		if err != nil {
 			log.Fatal(err)
 		}
 
@@ -147,10 +247,61 @@ func init() {
 
 	useCmd.Flags().StringVarP(&projectName, "name", "n", "", "name of the new project")
 	useCmd.Flags().IntVarP(&port, "port", "p", -1, "port for the application (optional)")
-	useCmd.Flags().StringVarP(&groupName, "group", "g", "", "group of the new project")
+	useCmd.Flags().StringVarP(&groupName, "group", "g", "", "full path of the group of the new project, or a username for a personal project")
 	useCmd.Flags().StringVarP(&description, "desc", "d", "", "description of the new project")
+	useCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print what would be created without changing anything in GitLab")
+	useCmd.Flags().BoolVar(&keepOnFailure, "keep-on-failure", false, "do not roll back the created project when a step fails (for debugging)")
+	useCmd.Flags().BoolVar(&resume, "resume", false, "finish a project whose creation was interrupted, skipping the steps already done")
+	useCmd.Flags().StringVar(&visibility, "visibility", "", "visibility of the new project: private, internal or public (default private)")
+	useCmd.Flags().StringSliceVar(&topics, "topics", nil, "topics of the new project (e.g. --topics backend,java)")
+	useCmd.Flags().StringVar(&avatarFile, "avatar", "", "image file to upload as the project avatar")
+	useCmd.Flags().BoolVar(&initReadme, "initialize-with-readme", false, "let GitLab create a README.md, which a README.md from the template replaces")
+	useCmd.Flags().BoolVar(&lfsEnabled, "lfs", false, "enable Git LFS for the new project (default is the instance setting)")
+	useCmd.Flags().BoolVar(&createGroup, "create-group", false, "create the missing groups of --group, each under its parent")
+	addTemplateFlags(useCmd)
+
+}
+
+// resumeRef 检查 --resume 能否继续目标项目上次未完成的运行，返回本次使用的模板版本。
+// 没有日志时没有可以继续的运行，模板与日志中记录的不同时拒绝继续，
+// 未指定版本时沿用上次运行解析出的提交 SHA。
+func resumeRef(project string, journal *workflow.Journal, unfinished bool, source scaffold.Source) (string, error) {
+	if !unfinished {
+		return "", fmt.Errorf("nothing to resume for %s", project)
+	}
 
-	useCmd.MarkFlagRequired("name")
-	useCmd.MarkFlagRequired("group")
+	// 本地模板未指定名称时以目录名作为模板名称，与 Source.Fetch 一致
+	name := source.Name
+	if name == "" && source.IsLocal() {
+		if dir, err := filepath.Abs(source.Dir); err == nil {
+			name = filepath.Base(dir)
+		}
+	}
+	if journal.Template != "" && journal.Template != name {
+		return "", fmt.Errorf("%s was started with template %s, resume it with the same template", project, journal.Template)
+	}
+
+	if source.IsLocal() || source.Ref != "" || journal.SHA == "" {
+		return source.Ref, nil
+	}
+	return journal.SHA, nil
+}
 
+// projectFlags 返回命令行中显式指定的项目设置
+func projectFlags(cmd *cobra.Command) scaffold.ProjectConfig {
+	var c scaffold.ProjectConfig
+	flags := cmd.Flags()
+	if flags.Changed("visibility") {
+		c.Visibility = visibility
+	}
+	if flags.Changed("topics") {
+		c.Topics = topics
+	}
+	if flags.Changed("initialize-with-readme") {
+		c.InitializeWithReadme = &initReadme
+	}
+	if flags.Changed("lfs") {
+		c.LFSEnabled = &lfsEnabled
+	}
+	return c
 }
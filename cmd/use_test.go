@@ -0,0 +1,50 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/imxw/gitlab-scaffold/internal/scaffold"
+	"github.com/imxw/gitlab-scaffold/internal/workflow"
+)
+
+func TestResumeRef(t *testing.T) {
+	journal := &workflow.Journal{Project: "team/app", Template: "backend-java", SHA: "abc123"}
+
+	tests := []struct {
+		name       string
+		unfinished bool
+		source     scaffold.Source
+		want       string
+		wantErr    string
+	}{
+		{"no journal", false, scaffold.Source{Name: "backend-java"}, "", "nothing to resume for team/app"},
+		{"reuse sha", true, scaffold.Source{Name: "backend-java"}, "abc123", ""},
+		{"explicit ref", true, scaffold.Source{Name: "backend-java", Ref: "v1.2.0"}, "v1.2.0", ""},
+		{"other template", true, scaffold.Source{Name: "frontend-vue"}, "", "started with template backend-java"},
+		{"local template", true, scaffold.Source{Name: "backend-java", Dir: "./tpl"}, "", ""},
+		{"local template named after dir", true, scaffold.Source{Dir: "/tmp/backend-java"}, "", ""},
+		{"local template of another name", true, scaffold.Source{Dir: "/tmp/frontend-vue"}, "", "started with template backend-java"},
+	}
+	for _, tt := range tests {
+		got, err := resumeRef("team/app", journal, tt.unfinished, tt.source)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want an error containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: ref = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
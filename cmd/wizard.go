@@ -0,0 +1,170 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/imxw/gitlab-scaffold/internal/gitlabx"
+	"github.com/imxw/gitlab-scaffold/internal/prompt"
+	"github.com/imxw/gitlab-scaffold/internal/scaffold"
+	"github.com/imxw/gitlab-scaffold/internal/values"
+)
+
+// askProjectInputs 询问未通过命令行提供的项目名、组、描述和端口
+func askProjectInputs(ctx context.Context, p *prompt.Prompter, client *gitlabx.Client, portSet bool) error {
+	var err error
+
+	if projectName == "" {
+		projectName, err = p.String("Project name", "", validateProjectName)
+		if err != nil {
+			return err
+		}
+	}
+
+	if groupName == "" {
+		groups, err := client.ListWritableGroups(ctx)
+		if err != nil {
+			return err
+		}
+		// 候选项只包含有 Developer 以上权限的组，个人命名空间和通过上级组继承权限的组
+		// 不在其中，输入的完整路径存在时同样接受
+		namespaceExists := func(s string) error {
+			if s == "" {
+				return errors.New("group is required")
+			}
+			_, err := client.GetNamespace(ctx, s)
+			return err
+		}
+		if len(groups) == 0 {
+			groupName, err = p.String("Group", "", namespaceExists)
+		} else {
+			groupName, err = p.Complete("Group", "", groups, namespaceExists)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if description == "" {
+		description, err = p.String("Description", projectName, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !portSet {
+		port, err = p.Int("Port (optional, leave empty for frontend projects)", port, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// askTemplateVariables 询问模板清单中声明但未提供或取值不合法的变量，结果写入 supplied
+func askTemplateVariables(p *prompt.Prompter, manifest *scaffold.Manifest, supplied values.Values) error {
+	for i := range manifest.Variables {
+		v := &manifest.Variables[i]
+
+		if raw, ok := v.Lookup(supplied); ok {
+			_, err := v.Convert(raw)
+			if err == nil {
+				continue
+			}
+			fmt.Printf("  ✗ %v\n", err)
+		}
+
+		label := v.Name
+		if v.Description != "" {
+			label = fmt.Sprintf("%s (%s)", v.Name, v.Description)
+		}
+		switch v.Type {
+		case scaffold.VarTypeEnum:
+			label += " [" + strings.Join(v.Options, "|") + "]"
+		case scaffold.VarTypeList:
+			label += " (comma separated)"
+		case scaffold.VarTypeBool:
+			label += " (true/false)"
+		}
+
+		answer, err := p.String(label, formatDefault(v.Default), func(s string) error {
+			if s == "" && !v.Required {
+				return nil
+			}
+			_, err := v.Convert(s)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		if answer == "" {
+			delete(supplied, v.Name)
+			continue
+		}
+		supplied[v.Name] = answer
+	}
+	return nil
+}
+
+// printSummary 输出即将创建的项目及其变量，供用户在调用 GitLab 之前确认
+func printSummary(w io.Writer, tmpl scaffold.TemplateInfo, vars map[string]interface{}) {
+	fmt.Fprintln(w, "\nAbout to create:")
+	fmt.Fprintf(w, "  template:    %s\n", tmpl.Location())
+	fmt.Fprintf(w, "  project:     %s/%s\n", groupName, projectName)
+	fmt.Fprintf(w, "  description: %s\n", description)
+	if port >= 0 {
+		fmt.Fprintf(w, "  port:        %d\n", port)
+	}
+
+	if len(vars) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// 变量中可能有密钥等敏感值，与 --dry-run 一样只显示名称
+	fmt.Fprintln(w, "  variables:")
+	for _, k := range keys {
+		fmt.Fprintf(w, "    %s = %s\n", k, maskedValue)
+	}
+	fmt.Fprintln(w)
+}
+
+func formatDefault(def interface{}) string {
+	switch v := def.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(def)
+}
+
+func validateProjectName(name string) error {
+	if name == "" {
+		return errors.New("project name is required")
+	}
+	if strings.ContainsAny(name, "/ ") {
+		return errors.New("project name must not contain '/' or spaces")
+	}
+	return nil
+}
@@ -23,11 +23,15 @@ var globalConfig Config
 type Config interface {
 	GetGitlab() gitlabx.Config
 	GetTemplate() scaffold.Config
+	GetProject() scaffold.ProjectConfig
+	GetGroup() gitlabx.GroupOptions
 }
 
 type configImpl struct {
-	Gitlab   gitlabx.Config  `mapstructure:"gitlab"`
-	Template scaffold.Config `mapstructure:"template"`
+	Gitlab   gitlabx.Config         `mapstructure:"gitlab"`
+	Template scaffold.Config        `mapstructure:"template"`
+	Project  scaffold.ProjectConfig `mapstructure:"project"`
+	Group    gitlabx.GroupOptions   `mapstructure:"group"`
 }
 
 func (c *configImpl) GetGitlab() gitlabx.Config {
@@ -38,6 +42,16 @@ func (c *configImpl) GetTemplate() scaffold.Config {
 	return c.Template
 }
 
+// GetProject 返回内置默认值与配置文件合并后的项目设置
+func (c *configImpl) GetProject() scaffold.ProjectConfig {
+	return scaffold.DefaultProjectConfig().Override(c.Project)
+}
+
+// GetGroup 返回 --create-group 自动创建组时使用的设置
+func (c *configImpl) GetGroup() gitlabx.GroupOptions {
+	return c.Group
+}
+
 // LoadConfig 加载配置文件并返回配置对象
 func LoadConfig() (Config, error) {
 
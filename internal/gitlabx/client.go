@@ -6,8 +6,14 @@ license that can be found in the LICENSE file.
 package gitlabx
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/xanzy/go-gitlab"
 )
@@ -15,25 +21,82 @@ import (
 type Config struct {
 	BaseURL string `mapstructure:"baseurl" validate:"url"`
 	Token   string `mapstructure:"token" validate:"required"`
+	// PerPage 是列表请求每页的条数，默认为 100
+	PerPage int `mapstructure:"per_page" validate:"omitempty,min=1,max=100"`
+	// MaxItems 是列表请求最多读取的条数，为 0 时不限制
+	MaxItems int `mapstructure:"max_items" validate:"omitempty,min=0"`
+	// Retry 是请求失败时的重试设置
+	Retry RetryConfig `mapstructure:"retry"`
+	// RateLimit 是每秒最多发出的请求数，为 0 时按 GitLab 返回的 RateLimit-Limit 限速
+	RateLimit float64 `mapstructure:"rate_limit" validate:"omitempty,min=0"`
+	// Timeout 限制一次命令中所有操作的总时间，为 0 时不限制
+	Timeout time.Duration `mapstructure:"timeout" validate:"omitempty,min=0"`
+	// OperationTimeout 限制每次客户端操作的时间，包括其中的分页请求和重试，默认为 5 分钟
+	OperationTimeout time.Duration `mapstructure:"operation_timeout" validate:"omitempty,min=0"`
 }
 
 // Client 结构体包含一个go-gitlab客户端实例
 type Client struct {
 	git *gitlab.Client
+
+	perPage  int
+	maxItems int
+
+	// timeout 是每次操作的超时时间，为 0 时不限制
+	timeout time.Duration
+
+	// retry 记录重试过的请求，只有 NewClientFromConfig 创建的客户端才有
+	retry *retrier
 }
 
 type FileData struct {
 	Content  string
 	Encoding string
+	// Mode 是模板中文件的权限，可执行文件在提交时会设置可执行位
+	Mode os.FileMode
+}
+
+// Size 返回文件解码后的字节数
+func (f *FileData) Size() int {
+	if b, err := f.Decode(); err == nil {
+		return len(b)
+	}
+	return len(f.Content)
+}
+
+// Decode 返回文件解码后的内容
+func (f *FileData) Decode() ([]byte, error) {
+	if f.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(f.Content)
+	}
+	return []byte(f.Content), nil
+}
+
+// Variable 描述一个项目 CI/CD 变量，不包含变量的值
+type Variable struct {
+	Key              string
+	EnvironmentScope string
+	Protected        bool
+	Masked           bool
+}
+
+// Runner 描述一个项目 Runner
+type Runner struct {
+	ID          int
+	Description string
 }
 
 // 默认的GitLab URL
 const defaultGitLabUrl = "https://gitlab.com"
 
+// defaultOperationTimeout 是未配置 operation_timeout 时每次操作的超时时间
+const defaultOperationTimeout = 5 * time.Minute
+
 // NewClient 函数创建一个新的GitLab客户端，接收GitLab的URL和token作为参数
 // 如果没有提供URL或者URL是默认的GitLab URL，那么会使用默认的GitLab URL创建客户端
 // 如果没有提供token，那么会返回一个错误
-func NewClient(url, token string) (*Client, error) {
+// options 是传给 go-gitlab 的其他客户端选项
+func NewClient(url, token string, options ...gitlab.ClientOptionFunc) (*Client, error) {
 	if token == "" {
 		return nil, errors.New("empty gitlab token provided")
 	}
@@ -42,46 +105,90 @@ func NewClient(url, token string) (*Client, error) {
 	var err error
 
 	if url == "" || url == defaultGitLabUrl {
-		git, err = gitlab.NewClient(token)
+		git, err = gitlab.NewClient(token, options...)
 	} else {
-		git, err = gitlab.NewClient(token, gitlab.WithBaseURL(url))
+		git, err = gitlab.NewClient(token, append(options, gitlab.WithBaseURL(url))...)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{git: git}, nil
+	return &Client{git: git, perPage: defaultPerPage}, nil
 }
 
-// getGroupID 方法获取给定组名的组ID，如果组不存在则返回错误
-func (c *Client) getGroupID(groupName string) (int, error) {
-	groups, _, err := c.git.Groups.ListGroups(&gitlab.ListGroupsOptions{
-		Search: gitlab.String(groupName),
+// NewClientFromConfig 根据配置文件中的 gitlab 部分创建客户端，
+// 请求失败时按配置重试，并在标准错误输出中提示每次重试
+func NewClientFromConfig(cfg Config) (*Client, error) {
+	r := newRetrier(cfg.Retry, cfg.RateLimit, os.Stderr)
+	c, err := NewClient(cfg.BaseURL, cfg.Token, r.options()...)
+	if err != nil {
+		return nil, err
+	}
+	c.retry = r
+	c.timeout = defaultOperationTimeout
+	if cfg.OperationTimeout > 0 {
+		c.timeout = cfg.OperationTimeout
+	}
+	if cfg.PerPage > 0 {
+		c.perPage = cfg.PerPage
+	}
+	c.maxItems = cfg.MaxItems
+	return c, nil
+}
+
+// withTimeout 为一次操作设置超时，ctx 被取消时操作中正在进行的请求也会被取消
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(ctx, c.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// ListWritableGroups 方法返回当前 token 可以在其中创建项目的所有组的完整路径
+func (c *Client) ListWritableGroups(ctx context.Context) ([]string, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	groups, _, err := listAll(ctx, c, nil, func(opt gitlab.ListOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.Group, *gitlab.Response, error) {
+		return c.git.Groups.ListGroups(&gitlab.ListGroupsOptions{
+			ListOptions:    opt,
+			MinAccessLevel: gitlab.AccessLevel(gitlab.DeveloperPermissions),
+			OrderBy:        gitlab.String("path"),
+		}, options...)
 	})
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
+	paths := make([]string, 0, len(groups))
 	for _, group := range groups {
-		if group.FullPath == groupName {
-			return group.ID, nil
-		}
+		paths = append(paths, group.FullPath)
 	}
 
-	return 0, fmt.Errorf("group %s not found", groupName)
+	return paths, nil
 }
 
-// ListProjectsInGroup 方法根据提供的组名获取组内的所有项目，返回一个包含项目名和项目描述的map，如果组不存在则返回错误
-func (c *Client) ListProjectsInGroup(groupName string) (map[string]string, error) {
+// ListProjectsInGroup 方法根据提供的组的完整路径获取组内的所有项目，返回一个包含项目名和项目描述的map，如果组不存在则返回错误
+func (c *Client) ListProjectsInGroup(ctx context.Context, groupName string) (map[string]string, error) {
 
-	groupId, err := c.getGroupID(groupName)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	ns, err := c.GetNamespace(ctx, groupName)
 	if err != nil {
 		return nil, err
 	}
+	if ns.Kind != NamespaceGroup {
+		return nil, fmt.Errorf("%s is a user namespace, not a group", groupName)
+	}
+	groupId := ns.ID
 
-	projects, _, err := c.git.Groups.ListGroupProjects(groupId, &gitlab.ListGroupProjectsOptions{
-		IncludeSubGroups: gitlab.Bool(false),
+	projects, _, err := listAll(ctx, c, nil, func(opt gitlab.ListOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.Project, *gitlab.Response, error) {
+		return c.git.Groups.ListGroupProjects(groupId, &gitlab.ListGroupProjectsOptions{
+			ListOptions:      opt,
+			IncludeSubGroups: gitlab.Bool(false),
+		}, options...)
 	})
 
 	res := make(map[string]string)
@@ -107,9 +214,12 @@ func (c *Client) ListProjectsInGroup(groupName string) (map[string]string, error
 // （即使项目不存在），函数将返回一个 nil error。
 // 如果请求失败（例如，由于网络问题、认证问题等），
 // 函数将返回相应的错误。
-func (c *Client) IsProjectExist(projectWithNamespace string) (bool, error) {
+func (c *Client) IsProjectExist(ctx context.Context, projectWithNamespace string) (bool, error) {
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
 
-	_, resp, err := c.git.Projects.GetProject(projectWithNamespace, &gitlab.GetProjectOptions{})
+	_, resp, err := c.git.Projects.GetProject(projectWithNamespace, &gitlab.GetProjectOptions{}, gitlab.WithContext(ctx))
 	if err != nil {
 		// 如果在尝试获取项目时发生错误，我们检查状态码。
 		// 如果状态码是 404，这意味着项目不存在，但请求是成功的。
@@ -125,22 +235,56 @@ func (c *Client) IsProjectExist(projectWithNamespace string) (bool, error) {
 	return true, nil
 }
 
+// ResolveRef 将项目的分支、标签或提交 SHA 解析为完整的提交 SHA。
+// ref 为空时解析项目的默认分支。
+func (c *Client) ResolveRef(ctx context.Context, projectWithNamespace, ref string) (string, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	if ref == "" {
+		project, _, err := c.git.Projects.GetProject(projectWithNamespace, &gitlab.GetProjectOptions{}, gitlab.WithContext(ctx))
+		if err != nil {
+			return "", err
+		}
+		ref = project.DefaultBranch
+	}
+
+	commit, resp, err := c.git.Commits.GetCommit(projectWithNamespace, ref, gitlab.WithContext(ctx))
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return "", fmt.Errorf("ref %q not found in %s", ref, projectWithNamespace)
+		}
+		return "", err
+	}
+
+	return commit.ID, nil
+}
+
 // GetProjectArchive 通过项目名（包括命名空间）获取指定项目的源码压缩包
 // 输入参数 projectWithNamespace 是包括命名空间的 GitLab 项目名。
+// 输入参数 ref 是分支、标签或提交 SHA，为空时获取默认分支。
 // 返回值是一个字节切片，其中包含了项目的 tar.gz 归档文件。如果在获取归档文件过程中发生错误，会返回一个非 nil 的 error。
-func (c *Client) GetProjectArchive(projectWithNamespace string) ([]byte, error) {
+func (c *Client) GetProjectArchive(ctx context.Context, projectWithNamespace, ref string) ([]byte, error) {
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
 
 	// 首先，需要根据项目名获取项目的详细信息
-	project, _, err := c.git.Projects.GetProject(projectWithNamespace, &gitlab.GetProjectOptions{})
+	project, _, err := c.git.Projects.GetProject(projectWithNamespace, &gitlab.GetProjectOptions{}, gitlab.WithContext(ctx))
 	if err != nil {
 		// 如果在获取项目信息过程中发生错误，返回 nil 和错误
 		return nil, err
 	}
 
-	// 然后，使用 GitLab 客户端的 Repositories.Archive 方法获取项目归档
-	data, _, err := c.git.Repositories.Archive(project.ID, &gitlab.ArchiveOptions{
+	opt := &gitlab.ArchiveOptions{
 		Format: gitlab.String("tar.gz"),
-	})
+	}
+	if ref != "" {
+		opt.SHA = gitlab.String(ref)
+	}
+
+	// 然后，使用 GitLab 客户端的 Repositories.Archive 方法获取项目归档
+	data, _, err := c.git.Repositories.Archive(project.ID, opt, gitlab.WithContext(ctx))
 
 	if err != nil {
 		// 如果在获取归档文件过程中发生错误，返回 nil 和错误
@@ -151,117 +295,405 @@ func (c *Client) GetProjectArchive(projectWithNamespace string) ([]byte, error)
 	return data, nil
 }
 
+// ProjectOptions 是创建项目时的可选设置
+type ProjectOptions struct {
+	Description string
+	// DefaultBranch 是新项目的初始默认分支，为空时使用 GitLab 实例的默认值
+	DefaultBranch string
+	// Visibility 为 private、internal 或 public，为空时为 private
+	Visibility string
+	Topics     []string
+	// InitializeWithReadme 为 true 时 GitLab 会在默认分支上创建 README.md
+	InitializeWithReadme bool
+	// LFSEnabled 为 nil 时使用 GitLab 实例的默认值
+	LFSEnabled *bool
+}
+
+// CheckVisibility 检查可见性是否合法，空字符串表示默认的 private
+func CheckVisibility(visibility string) error {
+	switch gitlab.VisibilityValue(visibility) {
+	case "", gitlab.PrivateVisibility, gitlab.InternalVisibility, gitlab.PublicVisibility:
+		return nil
+	}
+	return fmt.Errorf("unknown visibility %q, must be private, internal or public", visibility)
+}
+
 // CreateProjectInGroup 在指定的 GitLab 组内创建一个新项目。
 // name 参数是新项目的名称。
-// group 参数是项目所属的 GitLab 组的名称。
-// desc 参数是新项目的描述。
+// group 参数是项目所属的 GitLab 组的完整路径，也可以是用户名，即在个人命名空间中创建项目。
+// opt 参数是描述、默认分支、可见性等可选设置。
 // 如果项目创建成功，返回 nil error。
-// 如果在查找组 ID 或创建项目过程中出现错误，返回对应的 error。
-func (c *Client) CreateProjectInGroup(name, group, desc string) error {
-	namespaceID, err := c.getGroupID(group)
+// 如果在查找命名空间或创建项目过程中出现错误，返回对应的 error。
+func (c *Client) CreateProjectInGroup(ctx context.Context, name, group string, opt ProjectOptions) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	ns, err := c.GetNamespace(ctx, group)
 	if err != nil {
 		return err
 	}
+	namespaceID := ns.ID
+
+	visibility := gitlab.PrivateVisibility
+	if opt.Visibility != "" {
+		visibility = gitlab.VisibilityValue(opt.Visibility)
+	}
 
-	opt := &gitlab.CreateProjectOptions{
+	options := &gitlab.CreateProjectOptions{
 		Name:        gitlab.String(name),
-		Description: gitlab.String(desc),
+		Description: gitlab.String(opt.Description),
 		NamespaceID: gitlab.Int(namespaceID),
-		Visibility:  gitlab.Visibility(gitlab.PrivateVisibility),
+		Visibility:  gitlab.Visibility(visibility),
+		LFSEnabled:  opt.LFSEnabled,
+	}
+	if opt.DefaultBranch != "" {
+		options.DefaultBranch = gitlab.String(opt.DefaultBranch)
+	}
+	if len(opt.Topics) > 0 {
+		options.Topics = &opt.Topics
 	}
-	_, _, err = c.git.Projects.CreateProject(opt)
+	if opt.InitializeWithReadme {
+		options.InitializeWithReadme = gitlab.Bool(true)
+	}
+	_, _, err = c.git.Projects.CreateProject(options, gitlab.WithContext(ctx))
 	return err
 }
 
-func (c *Client) EnableRunner(sourceProjectID, targetProjectID string) error {
-	runners, _, err := c.git.Runners.ListProjectRunners(sourceProjectID, &gitlab.ListProjectRunnersOptions{})
+// UploadAvatar 上传项目头像，filename 用于 GitLab 判断图片格式
+func (c *Client) UploadAvatar(ctx context.Context, projectID, filename string, content []byte) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, _, err := c.git.Projects.UploadAvatar(projectID, bytes.NewReader(content), filename, gitlab.WithContext(ctx))
+	return err
+}
+
+// ListProjectRunners 返回项目的非共享 Runner，即 EnableRunner 会为新项目启用的 Runner
+func (c *Client) ListProjectRunners(ctx context.Context, projectID string) ([]Runner, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	runners, err := c.listProjectRunners(ctx, projectID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var res []Runner
 	for _, runner := range runners {
 		if !runner.IsShared {
-			_, _, err := c.git.Runners.EnableProjectRunner(targetProjectID, &gitlab.EnableProjectRunnerOptions{
-				RunnerID: runner.ID,
-			})
-			if err != nil {
-				fmt.Printf("Failed to enable runner %d for project %s: %v\n", runner.ID, targetProjectID, err)
-			} else {
-				fmt.Printf("Runner %d enabled for project %s\n", runner.ID, targetProjectID)
-			}
+			res = append(res, Runner{ID: runner.ID, Description: runner.Description})
 		}
 	}
+	return res, nil
+}
 
-	return nil
+// listProjectRunners 返回项目的所有 Runner
+func (c *Client) listProjectRunners(ctx context.Context, projectID string) ([]*gitlab.Runner, error) {
+	runners, _, err := listAll(ctx, c, nil, func(opt gitlab.ListOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.Runner, *gitlab.Response, error) {
+		return c.git.Runners.ListProjectRunners(projectID, &gitlab.ListProjectRunnersOptions{ListOptions: opt}, options...)
+	})
+	return runners, err
 }
 
-func (c *Client) CopyProjectVariables(sourceProjectID, targetProjectID string) error {
-	vars, _, err := c.git.ProjectVariables.ListVariables(sourceProjectID, &gitlab.ListProjectVariablesOptions{})
+// EnableRunner 为目标项目启用源项目的所有非共享 Runner，返回成功启用的 Runner ID。
+// 目标项目中已经启用的 Runner 会被跳过，不包含在返回值中，继续被中断的流程时不会重复启用。
+// 部分 Runner 启用失败时同时返回已启用的 Runner ID 和所有失败的错误，便于撤销。
+func (c *Client) EnableRunner(ctx context.Context, sourceProjectID, targetProjectID string) ([]int, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	runners, err := c.listProjectRunners(ctx, sourceProjectID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	existing, err := c.listProjectRunners(ctx, targetProjectID)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[int]bool, len(existing))
+	for _, runner := range existing {
+		present[runner.ID] = true
+	}
+
+	var enabled []int
+	var errs []error
+	for _, runner := range runners {
+		if runner.IsShared {
+			continue
+		}
+		if present[runner.ID] {
+			fmt.Printf("Runner %d already enabled for project %s\n", runner.ID, targetProjectID)
+			continue
+		}
+		_, _, err := c.git.Runners.EnableProjectRunner(targetProjectID, &gitlab.EnableProjectRunnerOptions{
+			RunnerID: runner.ID,
+		}, gitlab.WithContext(ctx))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("enable runner %d for project %s: %w", runner.ID, targetProjectID, err))
+		} else {
+			fmt.Printf("Runner %d enabled for project %s\n", runner.ID, targetProjectID)
+			enabled = append(enabled, runner.ID)
+		}
+	}
+
+	return enabled, errors.Join(errs...)
+}
+
+// DisableRunner 为项目停用 Runner，用于撤销 EnableRunner
+func (c *Client) DisableRunner(ctx context.Context, projectID string, runnerID int) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.git.Runners.DisableProjectRunner(projectID, runnerID, gitlab.WithContext(ctx))
+	return err
+}
+
+// listProjectVariables 返回项目的所有 CI/CD 变量，包含变量的值
+func (c *Client) listProjectVariables(ctx context.Context, projectID string) ([]*gitlab.ProjectVariable, error) {
+	vars, _, err := listAll(ctx, c, nil, func(opt gitlab.ListOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.ProjectVariable, *gitlab.Response, error) {
+		return c.git.ProjectVariables.ListVariables(projectID, (*gitlab.ListProjectVariablesOptions)(&opt), options...)
+	})
+	return vars, err
+}
+
+// ListProjectVariables 返回项目的 CI/CD 变量，只包含变量名、作用域和属性，不返回变量的值
+func (c *Client) ListProjectVariables(ctx context.Context, projectID string) ([]Variable, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	vars, err := c.listProjectVariables(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Variable, 0, len(vars))
+	for _, v := range vars {
+		res = append(res, Variable{
+			Key:              v.Key,
+			EnvironmentScope: v.EnvironmentScope,
+			Protected:        v.Protected,
+			Masked:           v.Masked,
+		})
+	}
+	return res, nil
+}
+
+// CopyProjectVariables 把源项目的 CI/CD 变量复制到目标项目，返回成功复制的变量（不包含值）。
+// 目标项目中已有相同名称和作用域的变量会被跳过，不包含在返回值中，继续被中断的流程时不会重复创建。
+// 部分变量复制失败时同时返回已复制的变量和所有失败的错误，便于撤销。
+func (c *Client) CopyProjectVariables(ctx context.Context, sourceProjectID, targetProjectID string) ([]Variable, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	vars, err := c.listProjectVariables(ctx, sourceProjectID)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(vars) == 0 {
 		fmt.Printf("No variables found in source project %s\n", sourceProjectID)
-		return nil
+		return nil, nil
 	}
 
+	existing, err := c.listProjectVariables(ctx, targetProjectID)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[[2]string]bool, len(existing))
+	for _, v := range existing {
+		present[[2]string{v.Key, v.EnvironmentScope}] = true
+	}
+
+	var copied []Variable
+	var errs []error
 	for _, v := range vars {
+		if present[[2]string{v.Key, v.EnvironmentScope}] {
+			fmt.Printf("Variable %s (scope %s) already exists in project %s\n", v.Key, v.EnvironmentScope, targetProjectID)
+			continue
+		}
 		_, _, err := c.git.ProjectVariables.CreateVariable(targetProjectID, &gitlab.CreateProjectVariableOptions{
 			Key:              gitlab.String(v.Key),
 			Value:            gitlab.String(v.Value),
 			Protected:        gitlab.Bool(v.Protected),
 			Masked:           gitlab.Bool(v.Masked),
 			EnvironmentScope: gitlab.String(v.EnvironmentScope),
-		})
+		}, gitlab.WithContext(ctx))
 		if err != nil {
-			fmt.Printf("Failed to copy variable %s to project %s: %v\n", v.Key, targetProjectID, err)
+			errs = append(errs, fmt.Errorf("copy variable %s (scope %s) to project %s: %w", v.Key, v.EnvironmentScope, targetProjectID, err))
 		} else {
 			fmt.Printf("Variable %s copied to project %s\n", v.Key, targetProjectID)
+			copied = append(copied, Variable{
+				Key:              v.Key,
+				EnvironmentScope: v.EnvironmentScope,
+				Protected:        v.Protected,
+				Masked:           v.Masked,
+			})
 		}
 	}
 
-	return nil
+	return copied, errors.Join(errs...)
 }
 
-func (c *Client) CreateCommitFromFiles(projectID string, files map[string]*FileData) error {
+// DeleteProjectVariable 删除项目中指定作用域的变量，用于撤销 CopyProjectVariables
+func (c *Client) DeleteProjectVariable(ctx context.Context, projectID string, v Variable) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.git.ProjectVariables.RemoveVariable(projectID, v.Key, &gitlab.RemoveProjectVariableOptions{
+		Filter: &gitlab.VariableFilter{EnvironmentScope: v.EnvironmentScope},
+	}, gitlab.WithContext(ctx))
+	return err
+}
+
+// CreateCommitFromFiles 把文件作为一个提交推送到 branch 分支。
+// 分支中已存在的文件（例如 GitLab 创建的 README.md）会被更新。
+func (c *Client) CreateCommitFromFiles(ctx context.Context, projectID, branch, message string, files map[string]*FileData) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	existing, err := c.listFiles(ctx, projectID, branch)
+	if err != nil {
+		return err
+	}
+
 	// 这个切片用于保存 CommitActionOptions
 	var actions []*gitlab.CommitActionOptions
 
 	for path, fileData := range files {
+		action := gitlab.FileCreate
+		if existing[strings.TrimPrefix(path, "/")] {
+			action = gitlab.FileUpdate
+		}
 		options := &gitlab.CommitActionOptions{
-			Action:   gitlab.FileAction(gitlab.FileCreate),
+			Action:   gitlab.FileAction(action),
 			FilePath: gitlab.String(path),
 			Content:  gitlab.String(fileData.Content),
 			Encoding: gitlab.String(fileData.Encoding),
 		}
+		if fileData.Mode&0111 != 0 {
+			options.ExecuteFilemode = gitlab.Bool(true)
+		}
 		actions = append(actions, options)
 	}
 
-	_, _, err := c.git.Commits.CreateCommit(projectID, &gitlab.CreateCommitOptions{
+	_, _, err = c.git.Commits.CreateCommit(projectID, &gitlab.CreateCommitOptions{
 		Actions:       actions,
-		Branch:        gitlab.String("master"),
-		CommitMessage: gitlab.String("init project [skip ci]"),
-	})
+		Branch:        gitlab.String(branch),
+		CommitMessage: gitlab.String(message),
+	}, gitlab.WithContext(ctx))
 	return err
 }
 
-func (c *Client) DeleteProject(projectID string) error {
-	_, err := c.git.Projects.DeleteProject(projectID)
+// listFiles 返回分支中所有文件的路径，分支不存在或仓库为空时返回空集合
+func (c *Client) listFiles(ctx context.Context, projectID, branch string) (map[string]bool, error) {
+	nodes, resp, err := listAll(ctx, c, treeKeyset, func(opt gitlab.ListOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.TreeNode, *gitlab.Response, error) {
+		return c.git.Repositories.ListTree(projectID, &gitlab.ListTreeOptions{
+			ListOptions: opt,
+			Ref:         gitlab.String(branch),
+			Recursive:   gitlab.Bool(true),
+		}, options...)
+	})
+
+	files := make(map[string]bool)
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return files, nil
+		}
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		if node.Type == "blob" {
+			files[node.Path] = true
+		}
+	}
+	return files, nil
+}
+
+// HasCommit 检查分支最近的提交中是否有提交信息为 message 的提交
+func (c *Client) HasCommit(ctx context.Context, projectID, branch, message string) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	commits, resp, err := c.git.Commits.ListCommits(projectID, &gitlab.ListCommitsOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 20},
+		RefName:     gitlab.String(branch),
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, commit := range commits {
+		if strings.TrimSpace(commit.Message) == strings.TrimSpace(message) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *Client) DeleteProject(ctx context.Context, projectID string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.git.Projects.DeleteProject(projectID, gitlab.WithContext(ctx))
 	return err
 }
 
-func (c *Client) CreateBranch(projectID, branch, ref string) error {
+func (c *Client) CreateBranch(ctx context.Context, projectID, branch, ref string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	_, _, err := c.git.Branches.CreateBranch(projectID, &gitlab.CreateBranchOptions{
 		Branch: gitlab.String(branch),
 		Ref:    gitlab.String(ref),
-	})
+	}, gitlab.WithContext(ctx))
+	return err
+}
+
+// BranchExists 检查项目中是否存在指定分支，与 IsProjectExist 一样，不存在时返回 false 和 nil error
+func (c *Client) BranchExists(ctx context.Context, projectID, branch string) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, resp, err := c.git.Branches.GetBranch(projectID, branch, gitlab.WithContext(ctx))
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *Client) DeleteBranch(ctx context.Context, projectID, branch string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.git.Branches.DeleteBranch(projectID, branch, gitlab.WithContext(ctx))
 	return err
 }
 
-func (c *Client) SetDefaultBranch(projectID, branch string) error {
+// GetDefaultBranch 返回项目的默认分支，空仓库返回空字符串
+func (c *Client) GetDefaultBranch(ctx context.Context, projectID string) (string, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	project, _, err := c.git.Projects.GetProject(projectID, &gitlab.GetProjectOptions{}, gitlab.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return project.DefaultBranch, nil
+}
+
+func (c *Client) SetDefaultBranch(ctx context.Context, projectID, branch string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	_, _, err := c.git.Projects.EditProject(projectID, &gitlab.EditProjectOptions{
 		DefaultBranch: gitlab.String(branch),
-	})
+	}, gitlab.WithContext(ctx))
 	return err
 }
@@ -0,0 +1,163 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package gitlabx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newMuxClient 创建连接到 httptest 服务器的客户端，请求按路径分发到 mux
+func newMuxClient(t *testing.T, mux *http.ServeMux) *Client {
+	t.Helper()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	maxRetries := 0
+	c, err := NewClientFromConfig(Config{BaseURL: srv.URL, Token: "token", Retry: RetryConfig{MaxRetries: &maxRetries}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestCopyProjectVariablesPartialFailure(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/src/variables", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []map[string]interface{}{
+			{"key": "A", "value": "1", "environment_scope": "*"},
+			{"key": "B", "value": "2", "environment_scope": "*"},
+		})
+	})
+	mux.HandleFunc("/api/v4/projects/dst/variables", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeJSON(w, http.StatusOK, []interface{}{})
+			return
+		}
+		var v map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&v)
+		if v["key"] == "B" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "invalid"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, v)
+	})
+	c := newMuxClient(t, mux)
+
+	copied, err := c.CopyProjectVariables(context.Background(), "src", "dst")
+	if err == nil {
+		t.Fatal("CopyProjectVariables() succeeded, want an error for variable B")
+	}
+	if len(copied) != 1 || copied[0].Key != "A" {
+		t.Errorf("copied = %+v, want only A", copied)
+	}
+}
+
+func TestEnableRunnerPartialFailure(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/src/runners", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []map[string]interface{}{
+			{"id": 1, "is_shared": false},
+			{"id": 2, "is_shared": false},
+			{"id": 3, "is_shared": true},
+		})
+	})
+	mux.HandleFunc("/api/v4/projects/dst/runners", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeJSON(w, http.StatusOK, []interface{}{})
+			return
+		}
+		var v map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&v)
+		if v["runner_id"] == float64(2) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"message": "403 Forbidden"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, v)
+	})
+	c := newMuxClient(t, mux)
+
+	enabled, err := c.EnableRunner(context.Background(), "src", "dst")
+	if err == nil {
+		t.Fatal("EnableRunner() succeeded, want an error for runner 2")
+	}
+	if len(enabled) != 1 || enabled[0] != 1 {
+		t.Errorf("enabled = %v, want [1]", enabled)
+	}
+}
+
+func TestCopyProjectVariablesSkipsExisting(t *testing.T) {
+	var created []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/src/variables", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []map[string]interface{}{
+			{"key": "A", "value": "1", "environment_scope": "*"},
+			{"key": "A", "value": "2", "environment_scope": "prod"},
+		})
+	})
+	mux.HandleFunc("/api/v4/projects/dst/variables", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			// 上次运行已经复制了作用域为 * 的 A
+			writeJSON(w, http.StatusOK, []map[string]interface{}{{"key": "A", "environment_scope": "*"}})
+			return
+		}
+		var v map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&v)
+		created = append(created, v["key"].(string)+"/"+v["environment_scope"].(string))
+		writeJSON(w, http.StatusCreated, v)
+	})
+	c := newMuxClient(t, mux)
+
+	copied, err := c.CopyProjectVariables(context.Background(), "src", "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0] != "A/prod" {
+		t.Errorf("created %v, want only A/prod", created)
+	}
+	if len(copied) != 1 || copied[0].EnvironmentScope != "prod" {
+		t.Errorf("copied = %+v, want only A/prod", copied)
+	}
+}
+
+func TestEnableRunnerSkipsEnabled(t *testing.T) {
+	var enabledIDs []float64
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/src/runners", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []map[string]interface{}{{"id": 1}, {"id": 2}})
+	})
+	mux.HandleFunc("/api/v4/projects/dst/runners", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeJSON(w, http.StatusOK, []map[string]interface{}{{"id": 1}})
+			return
+		}
+		var v map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&v)
+		enabledIDs = append(enabledIDs, v["runner_id"].(float64))
+		writeJSON(w, http.StatusCreated, v)
+	})
+	c := newMuxClient(t, mux)
+
+	enabled, err := c.EnableRunner(context.Background(), "src", "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(enabledIDs) != 1 || enabledIDs[0] != 2 {
+		t.Errorf("enabled runners %v on the server, want only 2", enabledIDs)
+	}
+	if len(enabled) != 1 || enabled[0] != 2 {
+		t.Errorf("enabled = %v, want [2]", enabled)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package gitlabx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+var (
+	// ErrNotFound 表示资源不存在，或者对当前 token 不可见
+	ErrNotFound = errors.New("not found")
+	// ErrForbidden 表示当前 token 没有权限访问资源
+	ErrForbidden = errors.New("permission denied")
+)
+
+// 命名空间的类型
+const (
+	NamespaceGroup = "group"
+	NamespaceUser  = "user"
+)
+
+// Namespace 是一个组或用户的命名空间
+type Namespace struct {
+	ID       int
+	FullPath string
+	// Kind 为 group 或 user
+	Kind string
+}
+
+// GetNamespace 按完整路径（如 team1/backend 或用户名）查找组或用户的命名空间。
+// 命名空间不存在或不可见时返回的错误包含 ErrNotFound，没有权限时包含 ErrForbidden。
+func (c *Client) GetNamespace(ctx context.Context, fullPath string) (*Namespace, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	ns, resp, err := c.git.Namespaces.GetNamespace(fullPath, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, namespaceError(fullPath, resp, err)
+	}
+	return &Namespace{ID: ns.ID, FullPath: ns.FullPath, Kind: ns.Kind}, nil
+}
+
+// namespaceError 根据响应状态码区分命名空间不存在和没有权限
+func namespaceError(fullPath string, resp *gitlab.Response, err error) error {
+	if resp == nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("namespace %s: %w (it does not exist or the token cannot see it)", fullPath, ErrNotFound)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("namespace %s: %w: %v", fullPath, ErrForbidden, err)
+	}
+	return err
+}
+
+// GroupOptions 是自动创建组时的设置，来自配置文件的 group 部分
+type GroupOptions struct {
+	// Visibility 为 private、internal 或 public，为空时为 private
+	Visibility  string `mapstructure:"visibility" validate:"omitempty,oneof=private internal public"`
+	Description string `mapstructure:"description"`
+}
+
+// MissingGroups 从 fullPath 开始逐级向上查找，返回最近的已存在的命名空间，
+// 以及需要从上到下依次创建的组的完整路径。fullPath 已存在时列表为空；
+// 所有层级都不存在时返回的命名空间为 nil，需要创建顶级组。
+// 某一级没有权限访问时返回包含 ErrForbidden 的错误。
+func (c *Client) MissingGroups(ctx context.Context, fullPath string) (*Namespace, []string, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	parts := strings.Split(strings.Trim(fullPath, "/"), "/")
+	for i := len(parts); i > 0; i-- {
+		ns, err := c.GetNamespace(ctx, strings.Join(parts[:i], "/"))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if ns.Kind != NamespaceGroup && i < len(parts) {
+			return nil, nil, fmt.Errorf("%s is a user namespace, subgroups cannot be created in it", ns.FullPath)
+		}
+		return ns, groupPaths(parts, i), nil
+	}
+	return nil, groupPaths(parts, 0), nil
+}
+
+// groupPaths 返回 parts 中从第 from 级开始的各级组的完整路径
+func groupPaths(parts []string, from int) []string {
+	var paths []string
+	for i := from; i < len(parts); i++ {
+		paths = append(paths, strings.Join(parts[:i+1], "/"))
+	}
+	return paths
+}
+
+// CreateGroup 按完整路径创建组，最后一级作为组的名称和路径，
+// 上一级必须已经存在，只有一级时创建顶级组。
+// 没有权限在上一级中创建组时返回包含 ErrForbidden 的错误。
+func (c *Client) CreateGroup(ctx context.Context, fullPath string, opt GroupOptions) (*Namespace, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	options := &gitlab.CreateGroupOptions{
+		Name:       gitlab.String(path.Base(fullPath)),
+		Path:       gitlab.String(path.Base(fullPath)),
+		Visibility: gitlab.Visibility(gitlab.PrivateVisibility),
+	}
+	if opt.Visibility != "" {
+		options.Visibility = gitlab.Visibility(gitlab.VisibilityValue(opt.Visibility))
+	}
+	if opt.Description != "" {
+		options.Description = gitlab.String(opt.Description)
+	}
+	if parent := path.Dir(fullPath); parent != "." {
+		ns, err := c.GetNamespace(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		options.ParentID = gitlab.Int(ns.ID)
+	}
+
+	group, resp, err := c.git.Groups.CreateGroup(options, gitlab.WithContext(ctx))
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return nil, fmt.Errorf("%w: %v", ErrForbidden, err)
+		}
+		return nil, err
+	}
+	return &Namespace{ID: group.ID, FullPath: group.FullPath, Kind: NamespaceGroup}, nil
+}
+
+// DeleteGroup 按完整路径删除组，组内的项目和子组会一起删除
+func (c *Client) DeleteGroup(ctx context.Context, fullPath string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.git.Groups.DeleteGroup(fullPath, gitlab.WithContext(ctx))
+	return err
+}
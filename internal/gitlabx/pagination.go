@@ -0,0 +1,100 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package gitlabx
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/xanzy/go-gitlab"
+)
+
+// defaultPerPage 是未配置 per_page 时每页的条数，也是 GitLab 允许的最大值
+const defaultPerPage = 100
+
+// treeKeyset 是仓库目录树的 keyset 分页参数，下一页由 Link 头中的 page_token 指定。
+// 组内项目等其他列表接口不支持 keyset 分页，使用页码分页。
+var treeKeyset = url.Values{"pagination": {"keyset"}}
+
+// listFunc 请求一页结果，options 用于传递 context 和附加 keyset 分页的参数
+type listFunc[T any] func(opt gitlab.ListOptions, options ...gitlab.RequestOptionFunc) ([]T, *gitlab.Response, error)
+
+// listAll 依次请求所有页面并合并结果，最多返回 max_items 条（为 0 时不限制）。
+// keyset 不为 nil 时是第一页的 keyset 分页参数，服务端不支持时退回到页码分页；为 nil 时使用页码分页。
+func listAll[T any](ctx context.Context, c *Client, keyset url.Values, list listFunc[T]) ([]T, *gitlab.Response, error) {
+	opt := gitlab.ListOptions{PerPage: c.perPage}
+	query := keyset
+
+	var all []T
+	for {
+		options := []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)}
+		if query != nil {
+			options = append(options, withQuery(query))
+		}
+
+		items, resp, err := list(opt, options...)
+		if err != nil {
+			return nil, resp, err
+		}
+		all = append(all, items...)
+
+		if c.maxItems > 0 && len(all) >= c.maxItems {
+			if len(all) > c.maxItems || hasNextPage(resp) {
+				fmt.Fprintf(os.Stderr, "Warning: more than %d items, the rest are ignored (gitlab.max_items)\n", c.maxItems)
+			}
+			return all[:c.maxItems], resp, nil
+		}
+
+		if next := nextLink(resp); query != nil && next != nil {
+			// keyset 分页的下一页参数在 Link 头中
+			query = next.Query()
+			opt.Page = 0
+			continue
+		}
+		if resp.NextPage == 0 {
+			return all, resp, nil
+		}
+		// 页码分页，或者服务端忽略了 keyset 参数
+		query = nil
+		opt.Page = resp.NextPage
+	}
+}
+
+func hasNextPage(resp *gitlab.Response) bool {
+	return resp.NextPage != 0 || nextLink(resp) != nil
+}
+
+// nextLink 解析 Link 头中 rel="next" 的地址，没有下一页时返回 nil
+func nextLink(resp *gitlab.Response) *url.URL {
+	for _, link := range strings.Split(resp.Header.Get("Link"), ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return nil
+		}
+		return u
+	}
+	return nil
+}
+
+// withQuery 用 query 中的参数替换请求中的同名参数
+func withQuery(query url.Values) gitlab.RequestOptionFunc {
+	return func(req *retryablehttp.Request) error {
+		q := req.URL.Query()
+		for k, vs := range query {
+			q[k] = vs
+		}
+		req.URL.RawQuery = q.Encode()
+		return nil
+	}
+}
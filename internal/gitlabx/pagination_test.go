@@ -0,0 +1,67 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package gitlabx
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestListFilesKeyset(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/team/app/repository/tree", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("pagination") != "keyset" || q.Has("order_by") || q.Has("page") {
+			t.Errorf("unexpected tree query %s", r.URL.RawQuery)
+		}
+		if q.Get("page_token") == "" {
+			next := "http://" + r.Host + r.URL.Path + "?pagination=keyset&per_page=100&recursive=true&ref=main&page_token=abc"
+			w.Header().Set("Link", "<"+next+`>; rel="next"`)
+			writeJSON(w, http.StatusOK, []map[string]string{{"path": "README.md", "type": "blob"}, {"path": "src", "type": "tree"}})
+			return
+		}
+		writeJSON(w, http.StatusOK, []map[string]string{{"path": "src/main.go", "type": "blob"}})
+	})
+	c := newMuxClient(t, mux)
+
+	files, err := c.listFiles(context.Background(), "team/app", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"README.md": true, "src/main.go": true}; !reflect.DeepEqual(files, want) {
+		t.Errorf("listFiles() = %v, want %v", files, want)
+	}
+}
+
+func TestListProjectsInGroupPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/namespaces/team", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": 7, "full_path": "team", "kind": "group"})
+	})
+	mux.HandleFunc("/api/v4/groups/7/projects", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Has("pagination") || q.Has("order_by") {
+			t.Errorf("unexpected keyset parameters in %s", r.URL.RawQuery)
+		}
+		if q.Get("page") == "2" {
+			writeJSON(w, http.StatusOK, []map[string]string{{"name": "web", "description": "frontend"}})
+			return
+		}
+		w.Header().Set("X-Next-Page", "2")
+		writeJSON(w, http.StatusOK, []map[string]string{{"name": "api", "description": "backend"}})
+	})
+	c := newMuxClient(t, mux)
+
+	projects, err := c.ListProjectsInGroup(context.Background(), "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"api": "backend", "web": "frontend"}; !reflect.DeepEqual(projects, want) {
+		t.Errorf("ListProjectsInGroup() = %v, want %v", projects, want)
+	}
+}
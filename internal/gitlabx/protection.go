@@ -0,0 +1,284 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package gitlabx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// 访问级别的名称，用于配置文件和模板清单
+var accessLevels = map[string]gitlab.AccessLevelValue{
+	"no one":     gitlab.NoPermissions,
+	"none":       gitlab.NoPermissions,
+	"developer":  gitlab.DeveloperPermissions,
+	"maintainer": gitlab.MaintainerPermissions,
+	"admin":      gitlab.AdminPermissions,
+}
+
+// ProtectedBranch 描述一个受保护分支，Name 可以是通配符，如 release/*。
+// 访问级别为 no one、developer、maintainer 或 admin，为空时使用 GitLab 的默认值。
+type ProtectedBranch struct {
+	Name                      string `mapstructure:"name" yaml:"name"`
+	PushAccessLevel           string `mapstructure:"push_access_level" yaml:"push_access_level"`
+	MergeAccessLevel          string `mapstructure:"merge_access_level" yaml:"merge_access_level"`
+	AllowForcePush            bool   `mapstructure:"allow_force_push" yaml:"allow_force_push"`
+	CodeOwnerApprovalRequired bool   `mapstructure:"code_owner_approval_required" yaml:"code_owner_approval_required"`
+}
+
+// ProtectedTag 描述一个受保护标签，Name 可以是通配符，如 v*
+type ProtectedTag struct {
+	Name              string `mapstructure:"name" yaml:"name"`
+	CreateAccessLevel string `mapstructure:"create_access_level" yaml:"create_access_level"`
+}
+
+// MergeSettings 是项目的合并请求设置，未设置的字段保持 GitLab 的默认值。
+// Method 为 merge、rebase_merge 或 ff，SquashOption 为 never、always、default_on 或 default_off。
+type MergeSettings struct {
+	Method               string `mapstructure:"method" yaml:"method"`
+	SquashOption         string `mapstructure:"squash_option" yaml:"squash_option"`
+	PipelinesMustSucceed *bool  `mapstructure:"pipelines_must_succeed" yaml:"pipelines_must_succeed"`
+	RemoveSourceBranch   *bool  `mapstructure:"remove_source_branch" yaml:"remove_source_branch"`
+}
+
+// IsZero 判断是否没有设置任何字段
+func (m MergeSettings) IsZero() bool {
+	return m.Method == "" && m.SquashOption == "" && m.PipelinesMustSucceed == nil && m.RemoveSourceBranch == nil
+}
+
+// Override 用 o 中设置了的字段覆盖 m
+func (m MergeSettings) Override(o MergeSettings) MergeSettings {
+	if o.Method != "" {
+		m.Method = o.Method
+	}
+	if o.SquashOption != "" {
+		m.SquashOption = o.SquashOption
+	}
+	if o.PipelinesMustSucceed != nil {
+		m.PipelinesMustSucceed = o.PipelinesMustSucceed
+	}
+	if o.RemoveSourceBranch != nil {
+		m.RemoveSourceBranch = o.RemoveSourceBranch
+	}
+	return m
+}
+
+// Check 检查合并方式和 squash 选项是否合法
+func (m MergeSettings) Check() error {
+	switch gitlab.MergeMethodValue(m.Method) {
+	case "", gitlab.NoFastForwardMerge, gitlab.RebaseMerge, gitlab.FastForwardMerge:
+	default:
+		return fmt.Errorf("unknown merge method %q", m.Method)
+	}
+	switch gitlab.SquashOptionValue(m.SquashOption) {
+	case "", gitlab.SquashOptionNever, gitlab.SquashOptionAlways, gitlab.SquashOptionDefaultOn, gitlab.SquashOptionDefaultOff:
+	default:
+		return fmt.Errorf("unknown squash option %q", m.SquashOption)
+	}
+	return nil
+}
+
+// CheckAccessLevel 检查访问级别的名称是否合法，空字符串表示使用默认值
+func CheckAccessLevel(level string) error {
+	_, err := accessLevel(level)
+	return err
+}
+
+func accessLevel(level string) (*gitlab.AccessLevelValue, error) {
+	if level == "" {
+		return nil, nil
+	}
+	v, ok := accessLevels[strings.ToLower(level)]
+	if !ok {
+		return nil, fmt.Errorf("unknown access level %q", level)
+	}
+	return gitlab.AccessLevel(v), nil
+}
+
+// accessLevelName 返回访问级别的名称
+func accessLevelName(v gitlab.AccessLevelValue) string {
+	switch v {
+	case gitlab.NoPermissions:
+		return "no one"
+	case gitlab.DeveloperPermissions:
+		return "developer"
+	case gitlab.MaintainerPermissions:
+		return "maintainer"
+	case gitlab.AdminPermissions:
+		return "admin"
+	}
+	return ""
+}
+
+// ListProtectedBranches 返回项目的受保护分支，只保留按角色设置的访问级别
+func (c *Client) ListProtectedBranches(ctx context.Context, projectID string) ([]ProtectedBranch, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	branches, _, err := listAll(ctx, c, nil, func(opt gitlab.ListOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.ProtectedBranch, *gitlab.Response, error) {
+		return c.git.ProtectedBranches.ListProtectedBranches(projectID, &gitlab.ListProtectedBranchesOptions{ListOptions: opt}, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]ProtectedBranch, 0, len(branches))
+	for _, b := range branches {
+		res = append(res, ProtectedBranch{
+			Name:                      b.Name,
+			PushAccessLevel:           roleAccessLevel(b.PushAccessLevels),
+			MergeAccessLevel:          roleAccessLevel(b.MergeAccessLevels),
+			AllowForcePush:            b.AllowForcePush,
+			CodeOwnerApprovalRequired: b.CodeOwnerApprovalRequired,
+		})
+	}
+	return res, nil
+}
+
+// roleAccessLevel 返回按角色（而不是用户或组）设置的访问级别
+func roleAccessLevel(levels []*gitlab.BranchAccessDescription) string {
+	for _, l := range levels {
+		if l.UserID == 0 && l.GroupID == 0 {
+			return accessLevelName(l.AccessLevel)
+		}
+	}
+	return ""
+}
+
+// ProtectBranch 保护分支。分支已受保护时（例如 GitLab 自动保护的默认分支）先取消保护再按设置重新保护。
+func (c *Client) ProtectBranch(ctx context.Context, projectID string, b ProtectedBranch) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	push, err := accessLevel(b.PushAccessLevel)
+	if err != nil {
+		return err
+	}
+	merge, err := accessLevel(b.MergeAccessLevel)
+	if err != nil {
+		return err
+	}
+
+	_, resp, err := c.git.ProtectedBranches.GetProtectedBranch(projectID, b.Name, gitlab.WithContext(ctx))
+	if err == nil {
+		if err := c.UnprotectBranch(ctx, projectID, b.Name); err != nil {
+			return err
+		}
+	} else if resp == nil || resp.StatusCode != 404 {
+		return err
+	}
+
+	_, _, err = c.git.ProtectedBranches.ProtectRepositoryBranches(projectID, &gitlab.ProtectRepositoryBranchesOptions{
+		Name:                      gitlab.String(b.Name),
+		PushAccessLevel:           push,
+		MergeAccessLevel:          merge,
+		AllowForcePush:            gitlab.Bool(b.AllowForcePush),
+		CodeOwnerApprovalRequired: gitlab.Bool(b.CodeOwnerApprovalRequired),
+	}, gitlab.WithContext(ctx))
+	return err
+}
+
+// UnprotectBranch 取消分支保护，用于撤销 ProtectBranch
+func (c *Client) UnprotectBranch(ctx context.Context, projectID, name string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.git.ProtectedBranches.UnprotectRepositoryBranches(projectID, name, gitlab.WithContext(ctx))
+	return err
+}
+
+// ListProtectedTags 返回项目的受保护标签，只保留按角色设置的访问级别
+func (c *Client) ListProtectedTags(ctx context.Context, projectID string) ([]ProtectedTag, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	tags, _, err := listAll(ctx, c, nil, func(opt gitlab.ListOptions, options ...gitlab.RequestOptionFunc) ([]*gitlab.ProtectedTag, *gitlab.Response, error) {
+		return c.git.ProtectedTags.ListProtectedTags(projectID, (*gitlab.ListProtectedTagsOptions)(&opt), options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]ProtectedTag, 0, len(tags))
+	for _, t := range tags {
+		tag := ProtectedTag{Name: t.Name}
+		for _, l := range t.CreateAccessLevels {
+			if l.UserID == 0 && l.GroupID == 0 {
+				tag.CreateAccessLevel = accessLevelName(l.AccessLevel)
+				break
+			}
+		}
+		res = append(res, tag)
+	}
+	return res, nil
+}
+
+// ProtectTag 保护标签
+func (c *Client) ProtectTag(ctx context.Context, projectID string, t ProtectedTag) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	create, err := accessLevel(t.CreateAccessLevel)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.git.ProtectedTags.ProtectRepositoryTags(projectID, &gitlab.ProtectRepositoryTagsOptions{
+		Name:              gitlab.String(t.Name),
+		CreateAccessLevel: create,
+	}, gitlab.WithContext(ctx))
+	return err
+}
+
+// UnprotectTag 取消标签保护，用于撤销 ProtectTag
+func (c *Client) UnprotectTag(ctx context.Context, projectID, name string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.git.ProtectedTags.UnprotectRepositoryTags(projectID, name, gitlab.WithContext(ctx))
+	return err
+}
+
+// GetMergeSettings 读取项目的合并请求设置
+func (c *Client) GetMergeSettings(ctx context.Context, projectID string) (MergeSettings, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	project, _, err := c.git.Projects.GetProject(projectID, &gitlab.GetProjectOptions{}, gitlab.WithContext(ctx))
+	if err != nil {
+		return MergeSettings{}, err
+	}
+
+	return MergeSettings{
+		Method:               string(project.MergeMethod),
+		SquashOption:         string(project.SquashOption),
+		PipelinesMustSucceed: gitlab.Bool(project.OnlyAllowMergeIfPipelineSucceeds),
+		RemoveSourceBranch:   gitlab.Bool(project.RemoveSourceBranchAfterMerge),
+	}, nil
+}
+
+// SetMergeSettings 修改项目的合并请求设置，未设置的字段保持不变
+func (c *Client) SetMergeSettings(ctx context.Context, projectID string, m MergeSettings) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	opt := &gitlab.EditProjectOptions{
+		OnlyAllowMergeIfPipelineSucceeds: m.PipelinesMustSucceed,
+		RemoveSourceBranchAfterMerge:     m.RemoveSourceBranch,
+	}
+	if m.Method != "" {
+		opt.MergeMethod = gitlab.MergeMethod(gitlab.MergeMethodValue(m.Method))
+	}
+	if m.SquashOption != "" {
+		opt.SquashOption = gitlab.SquashOption(gitlab.SquashOptionValue(m.SquashOption))
+	}
+
+	_, _, err := c.git.Projects.EditProject(projectID, opt, gitlab.WithContext(ctx))
+	return err
+}
@@ -0,0 +1,309 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package gitlabx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/xanzy/go-gitlab"
+	"golang.org/x/time/rate"
+)
+
+// 未配置时的重试次数和退避等待时间
+const (
+	defaultMaxRetries = 5
+	defaultWaitMin    = 500 * time.Millisecond
+	defaultWaitMax    = 30 * time.Second
+	// defaultMaxServerWait 是服务端通过 Retry-After 等要求等待的时间上限
+	defaultMaxServerWait = 2 * time.Minute
+)
+
+// GitLab 返回的限流响应头
+const (
+	headerRetryAfter     = "Retry-After"
+	headerRateLimit      = "RateLimit-Limit"
+	headerRateRemaining  = "RateLimit-Remaining"
+	headerRateLimitReset = "RateLimit-Reset"
+)
+
+// RetryConfig 是请求失败时的重试设置。限流（429）、502、503、504 和网络错误会重试，
+// 等待时间按指数增长并加入随机抖动，响应中有 Retry-After 或 RateLimit-Reset 时以它为准，
+// 服务端要求的等待时间超过 MaxServerWait 或者超出操作的剩余时间时不再重试。
+type RetryConfig struct {
+	// MaxRetries 是每个请求最多重试的次数，默认为 5，为 0 时不重试
+	MaxRetries *int `mapstructure:"max_retries" validate:"omitempty,min=0"`
+	// WaitMin 和 WaitMax 是退避等待时间的下限和上限，默认为 500ms 和 30s
+	WaitMin time.Duration `mapstructure:"wait_min" validate:"omitempty,min=0"`
+	WaitMax time.Duration `mapstructure:"wait_max" validate:"omitempty,min=0"`
+	// MaxServerWait 是服务端要求等待的时间上限，默认为 2m
+	MaxServerWait time.Duration `mapstructure:"max_server_wait" validate:"omitempty,min=0"`
+}
+
+// retrier 实现重试、退避和客户端限速，并记录重试过的请求。
+// 客户端按顺序发送请求，current 是正在发送的请求。
+type retrier struct {
+	out        io.Writer
+	maxRetries int
+	waitMin    time.Duration
+	waitMax    time.Duration
+	// maxServerWait 是 Retry-After、RateLimit-Reset 要求等待的时间上限
+	maxServerWait time.Duration
+
+	mu sync.Mutex
+	// limiter 限制每秒发出的请求数，autoLimit 为 true 时按第一个 RateLimit-Limit 响应头设置
+	limiter   *rate.Limiter
+	autoLimit bool
+	// pauseUntil 是 RateLimit-Remaining 为 0 时等待到的 RateLimit-Reset 时间
+	pauseUntil time.Time
+	current    string
+	lastErr    error
+	calls      []string
+	retried    map[string]*retriedCall
+}
+
+// retriedCall 是一个请求的重试次数和最后一次重试的原因
+type retriedCall struct {
+	retries int
+	reason  string
+}
+
+// newRetrier 根据配置创建 retrier，rateLimit 为 0 时按 GitLab 返回的 RateLimit-Limit 限速
+func newRetrier(cfg RetryConfig, rateLimit float64, out io.Writer) *retrier {
+	r := &retrier{
+		out:           out,
+		maxRetries:    defaultMaxRetries,
+		waitMin:       defaultWaitMin,
+		waitMax:       defaultWaitMax,
+		maxServerWait: defaultMaxServerWait,
+		limiter:       rate.NewLimiter(rate.Inf, 0),
+		autoLimit:     rateLimit == 0,
+		retried:       make(map[string]*retriedCall),
+	}
+	if cfg.MaxRetries != nil {
+		r.maxRetries = *cfg.MaxRetries
+	}
+	if cfg.WaitMin > 0 {
+		r.waitMin = cfg.WaitMin
+	}
+	if cfg.WaitMax > 0 {
+		r.waitMax = cfg.WaitMax
+	}
+	if cfg.MaxServerWait > 0 {
+		r.maxServerWait = cfg.MaxServerWait
+	}
+	if r.waitMax < r.waitMin {
+		r.waitMax = r.waitMin
+	}
+	if rateLimit > 0 {
+		r.limiter = rate.NewLimiter(rate.Limit(rateLimit), 1)
+	}
+	return r
+}
+
+// options 返回把 retrier 接入 go-gitlab 客户端的选项
+func (r *retrier) options() []gitlab.ClientOptionFunc {
+	return []gitlab.ClientOptionFunc{
+		gitlab.WithCustomRetryMax(r.maxRetries),
+		gitlab.WithCustomRetryWaitMinMax(r.waitMin, r.waitMax),
+		gitlab.WithCustomRetry(r.checkRetry),
+		gitlab.WithCustomBackoff(r.backoff),
+		gitlab.WithCustomLimiter(r),
+		gitlab.WithRequestLogHook(r.requestHook),
+		gitlab.WithResponseLogHook(r.responseHook),
+	}
+}
+
+// Wait 在每个请求发出之前调用：剩余配额用完时等到配额重置，然后按限速等待。
+// 配额重置的时间超过等待上限时直接返回错误。
+func (r *retrier) Wait(ctx context.Context) error {
+	r.mu.Lock()
+	pause := time.Until(r.pauseUntil)
+	limiter := r.limiter
+	r.mu.Unlock()
+
+	if err := r.checkServerWait(ctx, pause); err != nil {
+		return fmt.Errorf("rate limit exhausted: %w", err)
+	}
+	if pause > 0 {
+		timer := time.NewTimer(pause)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return limiter.Wait(ctx)
+}
+
+// requestHook 在每次发送请求之前调用，记录正在发送的请求
+func (r *retrier) requestHook(_ retryablehttp.Logger, req *http.Request, _ int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = req.Method + " " + req.URL.EscapedPath()
+	r.lastErr = nil
+}
+
+// responseHook 根据 RateLimit-* 响应头调整限速
+func (r *retrier) responseHook(_ retryablehttp.Logger, resp *http.Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// RateLimit-Limit 是每分钟允许的请求数
+	if r.autoLimit {
+		if limit, _ := strconv.ParseFloat(resp.Header.Get(headerRateLimit), 64); limit > 0 {
+			r.limiter = rate.NewLimiter(rate.Limit(limit/60), 1)
+			r.autoLimit = false
+		}
+	}
+	if resp.Header.Get(headerRateRemaining) == "0" {
+		if reset, ok := rateLimitReset(resp); ok {
+			r.pauseUntil = reset
+		}
+	}
+}
+
+// checkRetry 判断请求是否需要重试：限流、网关错误和网络错误会重试，取消或超时的请求不会
+func (r *retrier) checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if err != nil {
+		r.mu.Lock()
+		r.lastErr = err
+		r.mu.Unlock()
+		return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if wait, ok := retryAfter(resp); ok {
+			if err := r.checkServerWait(ctx, wait); err != nil {
+				return false, fmt.Errorf("%s: %w", resp.Status, err)
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// checkServerWait 检查服务端要求的等待时间不超过上限，也不超出 ctx 的剩余时间
+func (r *retrier) checkServerWait(ctx context.Context, wait time.Duration) error {
+	if wait > r.maxServerWait {
+		return fmt.Errorf("server asked to wait %s, longer than %s (gitlab.retry.max_server_wait)", wait.Round(time.Second), r.maxServerWait)
+	}
+	if deadline, ok := ctx.Deadline(); ok && wait > time.Until(deadline) {
+		return fmt.Errorf("server asked to wait %s, beyond the operation timeout", wait.Round(time.Second))
+	}
+	return nil
+}
+
+// backoff 返回第 attempt 次（从 0 开始）失败之后的等待时间，并记录这次重试
+func (r *retrier) backoff(min, max time.Duration, attempt int, resp *http.Response) time.Duration {
+	wait, ok := retryAfter(resp)
+	if !ok {
+		wait = exponentialJitter(min, max, attempt)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reason := "request failed"
+	if resp != nil {
+		reason = resp.Status
+	} else if r.lastErr != nil {
+		reason = r.lastErr.Error()
+	}
+	call, ok := r.retried[r.current]
+	if !ok {
+		call = &retriedCall{}
+		r.retried[r.current] = call
+		r.calls = append(r.calls, r.current)
+	}
+	call.retries++
+	call.reason = reason
+
+	fmt.Fprintf(r.out, "%s: %s, retrying in %s (%d/%d)\n", r.current, reason, wait.Round(time.Millisecond), attempt+1, r.maxRetries)
+	return wait
+}
+
+// exponentialJitter 返回 min 乘以 2 的 attempt 次方，不超过 max，并在其一半到全部之间随机取值
+func exponentialJitter(min, max time.Duration, attempt int) time.Duration {
+	wait := max
+	if attempt < 32 {
+		if d := min << uint(attempt); d > 0 && d < max {
+			wait = d
+		}
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// retryAfter 读取响应中服务端要求的等待时间：Retry-After（秒数或 HTTP 日期），
+// 限流时还会读取 RateLimit-Reset（Unix 时间戳）
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	if v := resp.Header.Get(headerRetryAfter); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return nonNegative(time.Until(t)), true
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if reset, ok := rateLimitReset(resp); ok {
+			return nonNegative(time.Until(reset)), true
+		}
+	}
+	return 0, false
+}
+
+// rateLimitReset 读取 RateLimit-Reset 响应头中的配额重置时间
+func rateLimitReset(resp *http.Response) (time.Time, bool) {
+	reset, err := strconv.ParseInt(resp.Header.Get(headerRateLimitReset), 10, 64)
+	if err != nil || reset <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(reset, 0), true
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// PrintRetrySummary 输出本次运行中重试过的请求，没有重试时不输出
+func (c *Client) PrintRetrySummary(w io.Writer) {
+	if c == nil || c.retry == nil {
+		return
+	}
+	r := c.retry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.calls) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Retried %d API call(s):\n", len(r.calls))
+	for _, name := range r.calls {
+		call := r.retried[name]
+		fmt.Fprintf(w, "  %s: %d retries, last: %s\n", name, call.retries, call.reason)
+	}
+}
@@ -0,0 +1,267 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package gitlabx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient 创建连接到 httptest 服务器的客户端，每次收到请求时调用 handler，
+// 参数是从 1 开始的请求序号
+func newTestClient(t *testing.T, cfg Config, handler func(w http.ResponseWriter, n int)) (*Client, *int32, *bytes.Buffer) {
+	t.Helper()
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, int(atomic.AddInt32(&hits, 1)))
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg.BaseURL = srv.URL
+	cfg.Token = "token"
+	if cfg.Retry.WaitMin == 0 {
+		cfg.Retry.WaitMin = time.Millisecond
+		cfg.Retry.WaitMax = 5 * time.Millisecond
+	}
+	c, err := NewClientFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	c.retry.out = out
+	return c, &hits, out
+}
+
+func writeProject(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"id":1}`))
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	c, hits, out := newTestClient(t, Config{}, func(w http.ResponseWriter, n int) {
+		switch n {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			writeProject(w)
+		}
+	})
+
+	exist, err := c.IsProjectExist(context.Background(), "team/app")
+	if err != nil || !exist {
+		t.Fatalf("IsProjectExist() = %t, %v, want true", exist, err)
+	}
+	if atomic.LoadInt32(hits) != 3 {
+		t.Errorf("server got %d requests, want 3", atomic.LoadInt32(hits))
+	}
+	if !strings.Contains(out.String(), "GET /api/v4/projects/team%2Fapp: 502 Bad Gateway, retrying in") {
+		t.Errorf("retry notice missing:\n%s", out)
+	}
+
+	summary := &bytes.Buffer{}
+	c.PrintRetrySummary(summary)
+	want := "Retried 1 API call(s):\n  GET /api/v4/projects/team%2Fapp: 2 retries, last: 429 Too Many Requests\n"
+	if summary.String() != want {
+		t.Errorf("summary = %q, want %q", summary, want)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	maxRetries := 2
+	c, hits, _ := newTestClient(t, Config{Retry: RetryConfig{MaxRetries: &maxRetries}}, func(w http.ResponseWriter, n int) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	if _, err := c.IsProjectExist(context.Background(), "team/app"); err == nil {
+		t.Fatal("IsProjectExist() succeeded, want an error")
+	}
+	if atomic.LoadInt32(hits) != 3 {
+		t.Errorf("server got %d requests, want 3", atomic.LoadInt32(hits))
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusForbidden, http.StatusNotFound} {
+		c, hits, _ := newTestClient(t, Config{}, func(w http.ResponseWriter, n int) {
+			w.WriteHeader(status)
+		})
+		c.IsProjectExist(context.Background(), "team/app")
+		if atomic.LoadInt32(hits) != 1 {
+			t.Errorf("status %d: server got %d requests, want 1", status, atomic.LoadInt32(hits))
+		}
+
+		summary := &bytes.Buffer{}
+		c.PrintRetrySummary(summary)
+		if summary.Len() != 0 {
+			t.Errorf("status %d: unexpected summary %q", status, summary)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	c, _, _ := newTestClient(t, Config{RateLimit: 20}, func(w http.ResponseWriter, n int) {
+		writeProject(w)
+	})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := c.IsProjectExist(context.Background(), "team/app"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 每秒 20 个请求，第 2 和第 3 个请求各等待 50ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests took %s, want at least 100ms", elapsed)
+	}
+}
+
+func TestRateLimitRemaining(t *testing.T) {
+	reset := time.Now().Add(time.Minute).Unix()
+	c, hits, _ := newTestClient(t, Config{}, func(w http.ResponseWriter, n int) {
+		w.Header().Set(headerRateRemaining, "0")
+		w.Header().Set(headerRateLimitReset, strconv.FormatInt(reset, 10))
+		writeProject(w)
+	})
+
+	if _, err := c.IsProjectExist(context.Background(), "team/app"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 配额用完后，下一个请求要等到 RateLimit-Reset
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.retry.Wait(ctx); err == nil {
+		t.Error("Wait() returned before RateLimit-Reset")
+	}
+	if atomic.LoadInt32(hits) != 1 {
+		t.Errorf("server got %d requests, want 1", atomic.LoadInt32(hits))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	r := newRetrier(RetryConfig{}, 0, &bytes.Buffer{})
+	min, max := 100*time.Millisecond, time.Second
+
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		got := r.backoff(min, max, attempt, &http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{}})
+		if got < want/2 || got > want {
+			t.Errorf("attempt %d: backoff = %s, want between %s and %s", attempt, got, want/2, want)
+		}
+	}
+
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+	resp.Header.Set(headerRetryAfter, "3")
+	if got := r.backoff(min, max, 0, resp); got != 3*time.Second {
+		t.Errorf("backoff with Retry-After: 3 = %s, want 3s", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := func(kv ...string) http.Header {
+		h := http.Header{}
+		for i := 0; i < len(kv); i += 2 {
+			h.Set(kv[i], kv[i+1])
+		}
+		return h
+	}
+	in := time.Now().Add(10 * time.Second)
+
+	tests := []struct {
+		name   string
+		resp   *http.Response
+		want   time.Duration
+		wantOK bool
+	}{
+		{"no response", nil, 0, false},
+		{"no header", &http.Response{StatusCode: 503, Header: header()}, 0, false},
+		{"seconds", &http.Response{StatusCode: 503, Header: header(headerRetryAfter, "7")}, 7 * time.Second, true},
+		{"http date", &http.Response{StatusCode: 503, Header: header(headerRetryAfter, in.UTC().Format(http.TimeFormat))}, 10 * time.Second, true},
+		{"rate limit reset", &http.Response{StatusCode: 429, Header: header(headerRateLimitReset, strconv.FormatInt(in.Unix(), 10))}, 10 * time.Second, true},
+		{"reset ignored without 429", &http.Response{StatusCode: 503, Header: header(headerRateLimitReset, strconv.FormatInt(in.Unix(), 10))}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.resp)
+		if ok != tt.wantOK || got > tt.want || got < tt.want-2*time.Second {
+			t.Errorf("%s: retryAfter() = %s, %t, want about %s, %t", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestOperationTimeout(t *testing.T) {
+	c, hits, _ := newTestClient(t, Config{OperationTimeout: 50 * time.Millisecond}, func(w http.ResponseWriter, n int) {
+		time.Sleep(time.Second)
+		writeProject(w)
+	})
+
+	start := time.Now()
+	_, err := c.IsProjectExist(context.Background(), "team/app")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("IsProjectExist() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("IsProjectExist() returned after %s, want about 50ms", elapsed)
+	}
+	if atomic.LoadInt32(hits) != 1 {
+		t.Errorf("server got %d requests, want 1 (timeouts are not retried)", atomic.LoadInt32(hits))
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	c, hits, _ := newTestClient(t, Config{}, func(w http.ResponseWriter, n int) {
+		writeProject(w)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.IsProjectExist(ctx, "team/app"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("IsProjectExist() error = %v, want context.Canceled", err)
+	}
+	if atomic.LoadInt32(hits) != 0 {
+		t.Errorf("server got %d requests, want 0", atomic.LoadInt32(hits))
+	}
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        Config
+		retryAfter string
+		wantErr    string
+	}{
+		{"longer than max_server_wait", Config{}, "3600", "longer than 2m0s"},
+		{"custom max_server_wait", Config{Retry: RetryConfig{MaxServerWait: 5 * time.Second}}, "10", "longer than 5s"},
+		{"beyond the operation timeout", Config{OperationTimeout: time.Second}, "30", "beyond the operation timeout"},
+	}
+	for _, tt := range tests {
+		c, hits, _ := newTestClient(t, tt.cfg, func(w http.ResponseWriter, n int) {
+			w.Header().Set(headerRetryAfter, tt.retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+
+		start := time.Now()
+		_, err := c.IsProjectExist(context.Background(), "team/app")
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: IsProjectExist() error = %v, want an error containing %q", tt.name, err, tt.wantErr)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("%s: IsProjectExist() returned after %s, want no wait", tt.name, elapsed)
+		}
+		if atomic.LoadInt32(hits) != 1 {
+			t.Errorf("%s: server got %d requests, want 1", tt.name, atomic.LoadInt32(hits))
+		}
+	}
+}
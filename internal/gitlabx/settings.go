@@ -0,0 +1,199 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package gitlabx
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// ProjectSettings 是从项目中读取的设置，键为 GitLab API 中的字段名
+type ProjectSettings map[string]interface{}
+
+// projectSetting 描述一个可以从模板项目复制的设置
+type projectSetting struct {
+	get func(p *gitlab.Project) interface{}
+	set func(opt *gitlab.EditProjectOptions, v interface{})
+}
+
+// projectSettings 是允许复制的设置，键为 GitLab API 中的字段名
+var projectSettings = map[string]projectSetting{
+	// CI/CD
+	"ci_config_path": {
+		get: func(p *gitlab.Project) interface{} { return p.CIConfigPath },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) { opt.CIConfigPath = gitlab.String(v.(string)) },
+	},
+	"build_timeout": {
+		get: func(p *gitlab.Project) interface{} { return p.BuildTimeout },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) { opt.BuildTimeout = gitlab.Int(v.(int)) },
+	},
+	"auto_devops_enabled": {
+		get: func(p *gitlab.Project) interface{} { return p.AutoDevopsEnabled },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) { opt.AutoDevopsEnabled = gitlab.Bool(v.(bool)) },
+	},
+
+	// 功能开关
+	"wiki_access_level": {
+		get: func(p *gitlab.Project) interface{} { return p.WikiAccessLevel },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.WikiAccessLevel = gitlab.AccessControl(v.(gitlab.AccessControlValue))
+		},
+	},
+	"issues_access_level": {
+		get: func(p *gitlab.Project) interface{} { return p.IssuesAccessLevel },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.IssuesAccessLevel = gitlab.AccessControl(v.(gitlab.AccessControlValue))
+		},
+	},
+	"snippets_access_level": {
+		get: func(p *gitlab.Project) interface{} { return p.SnippetsAccessLevel },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.SnippetsAccessLevel = gitlab.AccessControl(v.(gitlab.AccessControlValue))
+		},
+	},
+	"container_registry_access_level": {
+		get: func(p *gitlab.Project) interface{} { return p.ContainerRegistryAccessLevel },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.ContainerRegistryAccessLevel = gitlab.AccessControl(v.(gitlab.AccessControlValue))
+		},
+	},
+	"packages_enabled": {
+		get: func(p *gitlab.Project) interface{} { return p.PackagesEnabled },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) { opt.PackagesEnabled = gitlab.Bool(v.(bool)) },
+	},
+
+	// 合并请求
+	"merge_requests_access_level": {
+		get: func(p *gitlab.Project) interface{} { return p.MergeRequestsAccessLevel },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.MergeRequestsAccessLevel = gitlab.AccessControl(v.(gitlab.AccessControlValue))
+		},
+	},
+	"merge_method": {
+		get: func(p *gitlab.Project) interface{} { return p.MergeMethod },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.MergeMethod = gitlab.MergeMethod(v.(gitlab.MergeMethodValue))
+		},
+	},
+	"squash_option": {
+		get: func(p *gitlab.Project) interface{} { return p.SquashOption },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.SquashOption = gitlab.SquashOption(v.(gitlab.SquashOptionValue))
+		},
+	},
+	"only_allow_merge_if_pipeline_succeeds": {
+		get: func(p *gitlab.Project) interface{} { return p.OnlyAllowMergeIfPipelineSucceeds },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.OnlyAllowMergeIfPipelineSucceeds = gitlab.Bool(v.(bool))
+		},
+	},
+	"only_allow_merge_if_all_discussions_are_resolved": {
+		get: func(p *gitlab.Project) interface{} { return p.OnlyAllowMergeIfAllDiscussionsAreResolved },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.OnlyAllowMergeIfAllDiscussionsAreResolved = gitlab.Bool(v.(bool))
+		},
+	},
+	"remove_source_branch_after_merge": {
+		get: func(p *gitlab.Project) interface{} { return p.RemoveSourceBranchAfterMerge },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.RemoveSourceBranchAfterMerge = gitlab.Bool(v.(bool))
+		},
+	},
+	"resolve_outdated_diff_discussions": {
+		get: func(p *gitlab.Project) interface{} { return p.ResolveOutdatedDiffDiscussions },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.ResolveOutdatedDiffDiscussions = gitlab.Bool(v.(bool))
+		},
+	},
+	"printing_merge_request_link_enabled": {
+		get: func(p *gitlab.Project) interface{} { return p.PrintingMergeRequestLinkEnabled },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.PrintingMergeRequestLinkEnabled = gitlab.Bool(v.(bool))
+		},
+	},
+	"merge_commit_template": {
+		get: func(p *gitlab.Project) interface{} { return p.MergeCommitTemplate },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.MergeCommitTemplate = gitlab.String(v.(string))
+		},
+	},
+	"squash_commit_template": {
+		get: func(p *gitlab.Project) interface{} { return p.SquashCommitTemplate },
+		set: func(opt *gitlab.EditProjectOptions, v interface{}) {
+			opt.SquashCommitTemplate = gitlab.String(v.(string))
+		},
+	},
+}
+
+// ProjectSettingFields 返回所有允许复制的设置的字段名
+func ProjectSettingFields() []string {
+	fields := make([]string, 0, len(projectSettings))
+	for name := range projectSettings {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// CheckProjectSettingFields 检查字段名是否都在允许复制的范围内
+func CheckProjectSettingFields(fields []string) error {
+	for _, name := range fields {
+		if _, ok := projectSettings[name]; !ok {
+			return fmt.Errorf("project setting %q cannot be copied, supported settings are %v", name, ProjectSettingFields())
+		}
+	}
+	return nil
+}
+
+// GetProjectSettings 读取项目中 fields 指定的设置
+func (c *Client) GetProjectSettings(ctx context.Context, projectID string, fields []string) (ProjectSettings, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	if err := CheckProjectSettingFields(fields); err != nil {
+		return nil, err
+	}
+
+	project, _, err := c.git.Projects.GetProject(projectID, &gitlab.GetProjectOptions{}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(ProjectSettings, len(fields))
+	for _, name := range fields {
+		v := projectSettings[name].get(project)
+		// 空字符串表示使用默认值，或者是旧版本 GitLab 不返回的字段，不复制
+		switch v.(type) {
+		case string, gitlab.AccessControlValue, gitlab.MergeMethodValue, gitlab.SquashOptionValue:
+			if fmt.Sprint(v) == "" {
+				continue
+			}
+		}
+		settings[name] = v
+	}
+	return settings, nil
+}
+
+// EditProjectSettings 通过一次 EditProject 调用把 GetProjectSettings 读取的设置应用到项目
+func (c *Client) EditProjectSettings(ctx context.Context, projectID string, settings ProjectSettings) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	opt := &gitlab.EditProjectOptions{}
+	for name, v := range settings {
+		s, ok := projectSettings[name]
+		if !ok {
+			return fmt.Errorf("project setting %q cannot be copied", name)
+		}
+		s.set(opt, v)
+	}
+
+	_, _, err := c.git.Projects.EditProject(projectID, opt, gitlab.WithContext(ctx))
+	return err
+}
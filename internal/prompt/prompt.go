@@ -0,0 +1,207 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/imxw/gitlab-scaffold/internal/stringx"
+)
+
+// ErrAborted 在用户拒绝确认或输入流结束时返回
+var ErrAborted = errors.New("aborted by user")
+
+// Prompter 在终端中逐项询问用户输入
+type Prompter struct {
+	ctx context.Context
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// New 创建一个从 in 读取、向 out 输出提示的 Prompter，ctx 被取消时正在等待的输入立即返回
+func New(ctx context.Context, in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{ctx: ctx, in: bufio.NewReader(in), out: out}
+}
+
+// IsTerminal 判断 f 是否连接到一个终端
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// readLine 读取一行输入并去掉首尾空白。ctx 被取消时返回 ctx 的错误，
+// 读取输入的 goroutine 会留到进程退出，之后不应再使用这个 Prompter
+func (p *Prompter) readLine() (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		line, err := p.in.ReadString('\n')
+		ch <- result{line, err}
+	}()
+
+	var line string
+	var err error
+	select {
+	case <-p.ctx.Done():
+		return "", p.ctx.Err()
+	case r := <-ch:
+		line, err = r.line, r.err
+	}
+	if err != nil {
+		if err == io.EOF && line != "" {
+			return strings.TrimSpace(line), nil
+		}
+		if err == io.EOF {
+			return "", ErrAborted
+		}
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// String 询问一个字符串，直接回车时使用默认值 def。
+// validate 不为 nil 时会校验输入，校验失败会显示错误并重新询问。
+func (p *Prompter) String(label, def string, validate func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", label, def)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", label)
+		}
+
+		answer, err := p.readLine()
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			answer = def
+		}
+
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				fmt.Fprintf(p.out, "  ✗ %v\n", err)
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+// Int 询问一个整数，直接回车时使用默认值 def；optional 为 true 时允许留空并返回 def
+func (p *Prompter) Int(label string, def int, optional bool) (int, error) {
+	defStr := ""
+	if !optional || def >= 0 {
+		defStr = strconv.Itoa(def)
+	}
+
+	answer, err := p.String(label, defStr, func(s string) error {
+		if s == "" && optional {
+			return nil
+		}
+		if _, err := strconv.Atoi(s); err != nil {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if answer == "" {
+		return def, nil
+	}
+	return strconv.Atoi(answer)
+}
+
+// Confirm 询问是否继续，直接回车时使用默认值 def
+func (p *Prompter) Confirm(label string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		fmt.Fprintf(p.out, "%s [%s]: ", label, hint)
+		answer, err := p.readLine()
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(p.out, "  ✗ please answer y or n")
+	}
+}
+
+// Complete 在候选项中模糊匹配用户的输入：唯一匹配或完全匹配时直接返回，
+// 有多个匹配时列出候选项，用户可以输入序号选择或继续输入以缩小范围。
+// accept 不为 nil 时，不在候选项中的输入通过 accept 检查后也直接返回。
+func (p *Prompter) Complete(label, def string, candidates []string, accept func(string) error) (string, error) {
+	query := ""
+	for {
+		answer, err := p.String(label, def, nil)
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			fmt.Fprintln(p.out, "  ✗ a value is required")
+			continue
+		}
+
+		// 在上一次列出的候选项中按序号选择
+		matches := stringx.FuzzyFind(query, candidates)
+		if n, err := strconv.Atoi(answer); err == nil && query != "" && n >= 1 && n <= len(matches) {
+			return matches[n-1], nil
+		}
+
+		if stringx.StringInSlice(answer, candidates) {
+			return answer, nil
+		}
+
+		var acceptErr error
+		if accept != nil {
+			if acceptErr = accept(answer); acceptErr == nil {
+				return answer, nil
+			}
+		}
+
+		query = answer
+		matches = stringx.FuzzyFind(query, candidates)
+		switch len(matches) {
+		case 0:
+			if acceptErr != nil {
+				fmt.Fprintf(p.out, "  ✗ no match for %q: %v\n", answer, acceptErr)
+				continue
+			}
+			fmt.Fprintf(p.out, "  ✗ no match for %q\n", answer)
+		case 1:
+			fmt.Fprintf(p.out, "  → %s\n", matches[0])
+			return matches[0], nil
+		default:
+			for i, m := range matches {
+				fmt.Fprintf(p.out, "  %2d) %s\n", i+1, m)
+			}
+			fmt.Fprintln(p.out, "  enter a number to choose, or type more to narrow the list")
+			def = ""
+		}
+	}
+}
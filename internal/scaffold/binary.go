@@ -0,0 +1,41 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// sniffLen 是检查 NUL 字节时读取的最大长度，与 git 判断二进制文件的做法一致
+const sniffLen = 8000
+
+// IsBinary 根据文件内容判断是否为二进制文件：
+// 包含 NUL 字节、不是合法的 UTF-8，或者 MIME 类型不属于文本类型时视为二进制。
+// 二进制文件必须按 base64 编码提交，否则内容会被破坏。
+func IsBinary(content []byte) bool {
+	sample := content
+	if len(sample) > sniffLen {
+		sample = sample[:sniffLen]
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+
+	if !utf8.Valid(content) {
+		return true
+	}
+
+	// json、xml、html 等文本格式在 mimetype 中都以 text/plain 为祖先
+	for m := mimetype.Detect(content); m != nil; m = m.Parent() {
+		if m.Is("text/plain") {
+			return false
+		}
+	}
+	return true
+}
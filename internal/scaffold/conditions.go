@@ -0,0 +1,185 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Condition 根据变量决定是否包含匹配 Path 的文件或目录，Path 的写法与 Rule 相同。
+//
+// When 是一个模板表达式，结果为真时才包含匹配的路径，例如：
+//
+//	when: .Vars.database == "mysql"
+//	when: .Vars.grpc && .Vars.database != "none"
+//	when: eq .Vars.database "mysql"
+//
+// ==、!=、&&、|| 和前缀 ! 会被转换为 text/template 中的 eq、ne、and、or、not。
+// 一个路径匹配多个条件时，所有条件都成立才会被包含。
+type Condition struct {
+	Path string `yaml:"path"`
+	When string `yaml:"when"`
+}
+
+// compiledCondition 是编译后的 Condition
+type compiledCondition struct {
+	Condition
+	glob
+	tmpl *template.Template
+}
+
+// Conditions 是一组编译后的条件
+type Conditions []compiledCondition
+
+// CompileConditions 编译条件，路径模式或表达式不合法时返回错误
+func CompileConditions(conditions []Condition) (Conditions, error) {
+	compiled := make(Conditions, 0, len(conditions))
+	for _, c := range conditions {
+		if strings.TrimSpace(c.When) == "" {
+			return nil, fmt.Errorf("condition for %q has no 'when' expression", c.Path)
+		}
+
+		g, err := compileGlob(c.Path)
+		if err != nil {
+			return nil, fmt.Errorf("condition for %q: %v", c.Path, err)
+		}
+
+		expr := translateCondition(c.When)
+		tmpl, err := template.New(c.Path).Funcs(funcMap()).Parse("{{ if " + expr + " }}true{{ end }}")
+		if err != nil {
+			return nil, fmt.Errorf("condition for %q: invalid expression %q: %v", c.Path, c.When, err)
+		}
+
+		compiled = append(compiled, compiledCondition{Condition: c, glob: g, tmpl: tmpl})
+	}
+	return compiled, nil
+}
+
+// Include 判断 path 是否应被包含，即所有匹配 path 的条件都成立
+func (cs Conditions) Include(path string, isDir bool, data TemplateData) (bool, error) {
+	for _, c := range cs {
+		if !c.matches(path, isDir) {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := c.tmpl.Execute(&buf, data); err != nil {
+			return false, fmt.Errorf("condition for %q: %v", c.Path, err)
+		}
+		if buf.String() != "true" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// translateCondition 将 ==、!=、&&、||、! 组成的表达式转换为 text/template 的写法
+func translateCondition(expr string) string {
+	expr = strings.TrimSpace(expr)
+
+	if parts := splitOperator(expr, "||"); len(parts) > 1 {
+		return "or " + wrapAll(parts)
+	}
+	if parts := splitOperator(expr, "&&"); len(parts) > 1 {
+		return "and " + wrapAll(parts)
+	}
+	if parts := splitOperator(expr, "=="); len(parts) == 2 {
+		return "eq " + wrapAll(parts)
+	}
+	if parts := splitOperator(expr, "!="); len(parts) == 2 {
+		return "ne " + wrapAll(parts)
+	}
+	if strings.HasPrefix(expr, "!") && !strings.HasPrefix(expr, "!=") {
+		return "not (" + translateCondition(expr[1:]) + ")"
+	}
+	return translateGroups(expr)
+}
+
+// translateGroups 转换表达式中每个最外层括号内的内容，如 (.Vars.a == "x") 或 not (.Vars.b || .Vars.c)
+func translateGroups(expr string) string {
+	var b strings.Builder
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' && i+1 < len(expr) {
+				if depth == 0 {
+					b.WriteByte(c)
+				}
+				i++
+				c = expr[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case c == ')' && depth > 0:
+			depth--
+			if depth == 0 {
+				b.WriteString("(" + translateCondition(expr[start:i]) + ")")
+				continue
+			}
+		}
+		if depth == 0 {
+			b.WriteByte(c)
+		}
+	}
+	// 括号不配对时原样返回，由模板解析报告错误
+	if depth != 0 {
+		return expr
+	}
+	return b.String()
+}
+
+func wrapAll(parts []string) string {
+	wrapped := make([]string, len(parts))
+	for i, p := range parts {
+		wrapped[i] = "(" + translateCondition(p) + ")"
+	}
+	return strings.Join(wrapped, " ")
+}
+
+// splitOperator 以 op 切分表达式，忽略引号和括号内的内容
+func splitOperator(expr, op string) []string {
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(expr[i:], op):
+			parts = append(parts, strings.TrimSpace(expr[start:i]))
+			i += len(op) - 1
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(expr[start:]))
+}
@@ -0,0 +1,90 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranslateCondition(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`.Vars.grpc`, `.Vars.grpc`},
+		{`eq .Vars.db "mysql"`, `eq .Vars.db "mysql"`},
+		{`.Vars.db == "mysql"`, `eq (.Vars.db) ("mysql")`},
+		{`.Vars.db != "none"`, `ne (.Vars.db) ("none")`},
+		{`!.Vars.grpc`, `not (.Vars.grpc)`},
+		{`.Vars.a && .Vars.b && .Vars.c`, `and (.Vars.a) (.Vars.b) (.Vars.c)`},
+		{`.Vars.a || .Vars.b && .Vars.c`, `or (.Vars.a) (and (.Vars.b) (.Vars.c))`},
+		{`(.Vars.a == "x") || .Vars.b`, `or ((eq (.Vars.a) ("x"))) (.Vars.b)`},
+		{`!(.Vars.a || .Vars.b)`, `not ((or (.Vars.a) (.Vars.b)))`},
+		{`not (.Vars.db == "none")`, `not (eq (.Vars.db) ("none"))`},
+		{`.Vars.name == "a || b"`, `eq (.Vars.name) ("a || b")`},
+		{`.Vars.name == "(x"`, `eq (.Vars.name) ("(x")`},
+	}
+	for _, tt := range tests {
+		if got := translateCondition(tt.in); got != tt.want {
+			t.Errorf("translateCondition(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConditionsInclude(t *testing.T) {
+	data := TemplateData{Vars: map[string]interface{}{
+		"database": "mysql",
+		"grpc":     false,
+		"cache":    true,
+		"name":     "a || b",
+	}}
+
+	tests := []struct {
+		when string
+		want bool
+	}{
+		{`.Vars.database == "mysql"`, true},
+		{`.Vars.database != "mysql"`, false},
+		{`.Vars.grpc`, false},
+		{`!.Vars.grpc`, true},
+		{`.Vars.grpc && .Vars.database != "none"`, false},
+		{`.Vars.grpc || .Vars.cache`, true},
+		{`(.Vars.database == "postgres") || .Vars.cache`, true},
+		{`(.Vars.database == "postgres") || (.Vars.grpc && .Vars.cache)`, false},
+		{`!(.Vars.grpc || .Vars.database == "none")`, true},
+		{`.Vars.name == "a || b"`, true},
+		{`eq .Vars.database "mysql"`, true},
+	}
+	for _, tt := range tests {
+		cs, err := CompileConditions([]Condition{{Path: "db/", When: tt.when}})
+		if err != nil {
+			t.Errorf("CompileConditions(%q) error: %v", tt.when, err)
+			continue
+		}
+		got, err := cs.Include("db/schema.sql", false, data)
+		if err != nil {
+			t.Errorf("%q: Include() error: %v", tt.when, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: Include() = %t, want %t", tt.when, got, tt.want)
+		}
+
+		// 不匹配的路径不受条件影响
+		if got, _ := cs.Include("api/service.proto", false, data); !got {
+			t.Errorf("%q: Include() excluded a path the condition does not match", tt.when)
+		}
+	}
+
+	for _, when := range []string{"", `.Vars.a == `, `(.Vars.a`} {
+		if _, err := CompileConditions([]Condition{{Path: "db/", When: when}}); err == nil {
+			t.Errorf("CompileConditions(%q) succeeded, want an error", when)
+		} else if when == "" && !strings.Contains(err.Error(), "no 'when' expression") {
+			t.Errorf("CompileConditions(%q) error = %v", when, err)
+		}
+	}
+}
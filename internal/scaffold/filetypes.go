@@ -0,0 +1,94 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/imxw/gitlab-scaffold/internal/stringx"
+)
+
+// 配置的扩展名列表与默认列表的组合方式
+const (
+	// ModeMerge 在默认列表的基础上追加配置的扩展名
+	ModeMerge = "merge"
+	// ModeReplace 用配置的扩展名替换默认列表
+	ModeReplace = "replace"
+)
+
+// FileTypes 决定模板中的文件如何处理：
+// 扩展名在 Extensions 中或文件名在 Files 中的文件会被渲染，
+// 扩展名在 Base64Extensions 中的文件按 base64 编码提交，其余文件原样提交。
+type FileTypes struct {
+	Extensions       []string `mapstructure:"extensions" yaml:"extensions"`
+	Base64Extensions []string `mapstructure:"base64_extensions" yaml:"base64_extensions"`
+	Files            []string `mapstructure:"files" yaml:"files"`
+	// Mode 为 merge（默认）或 replace
+	Mode string `mapstructure:"extensions_mode" yaml:"extensions_mode"`
+}
+
+// DefaultFileTypes 返回内置的文件类型列表
+func DefaultFileTypes() FileTypes {
+	return FileTypes{
+		Extensions:       append([]string(nil), defaultExtensions...),
+		Base64Extensions: append([]string(nil), defaultBase64Extensions...),
+		Files:            append([]string(nil), defaultFiles...),
+	}
+}
+
+// Override 用 o 中的列表覆盖或扩充 t，返回新的 FileTypes。
+// o.Mode 为 replace 时，o 中非空的列表会替换 t 中对应的列表。
+func (t FileTypes) Override(o FileTypes) (FileTypes, error) {
+	var replace bool
+	switch strings.ToLower(o.Mode) {
+	case "", ModeMerge:
+	case ModeReplace:
+		replace = true
+	default:
+		return t, fmt.Errorf("unknown extensions_mode %q, expected %s or %s", o.Mode, ModeMerge, ModeReplace)
+	}
+
+	combine := func(base, extra []string, normalize func(string) string) []string {
+		var res []string
+		if !replace || len(extra) == 0 {
+			res = append(res, base...)
+		}
+		for _, s := range extra {
+			s = normalize(s)
+			if !stringx.StringInSlice(s, res) {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+
+	return FileTypes{
+		Extensions:       combine(t.Extensions, o.Extensions, normalizeExt),
+		Base64Extensions: combine(t.Base64Extensions, o.Base64Extensions, normalizeExt),
+		Files:            combine(t.Files, o.Files, strings.TrimSpace),
+	}, nil
+}
+
+// IsTemplate 判断文件是否需要按模板渲染
+func (t FileTypes) IsTemplate(name string) bool {
+	return stringx.StringInSlice(strings.ToLower(filepath.Ext(name)), t.Extensions) || stringx.StringInSlice(name, t.Files)
+}
+
+// IsBase64 判断文件是否需要按 base64 编码提交
+func (t FileTypes) IsBase64(name string) bool {
+	return stringx.StringInSlice(strings.ToLower(filepath.Ext(name)), t.Base64Extensions)
+}
+
+// normalizeExt 将扩展名统一为带前导点的小写形式，如 "TS" 转换为 ".ts"
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
@@ -0,0 +1,277 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/imxw/gitlab-scaffold/internal/stringx"
+)
+
+// funcMap 返回文件内容和路径名渲染时共用的模板函数。
+// 参数顺序与 sprig 保持一致，被处理的值放在最后，便于在管道中使用，
+// 例如 {{ .Vars.package | replace "." "/" }}。
+//
+// 命名转换：
+//
+//	ToPascalCase、ToCamelCase、SkipFirstPart、SkipLastPart、SkipFirstAndLastPart  按 '-' 处理项目名
+//	pascalcase、camelcase、snakecase、kebabcase、screamingcase、dotcase、pathcase  按单词边界转换
+//	upper、lower、title
+//
+// 字符串：
+//
+//	replace OLD NEW S、trim S、trimPrefix P S、trimSuffix P S、split SEP S、join SEP LIST、
+//	contains SUB S、hasPrefix P S、hasSuffix P S、quote S、repeat N S、
+//	indent N S、nindent N S
+//
+// 逻辑：
+//
+//	default DEFAULT VALUE、required MSG VALUE、ternary TRUE FALSE COND、empty VALUE、coalesce VALUES...
+//
+// 时间与随机：
+//
+//	now、year、date LAYOUT TIME、randAlphaNum N、uuid
+//
+// 编码：
+//
+//	sha256sum S、b64enc S、b64dec S、toYaml V、toJson V、toPrettyJson V
+//
+// 列表与字典：
+//
+//	list ITEMS...、dict K V...、append LIST ITEM、has ITEM LIST、first LIST、last LIST、
+//	uniq LIST、keys DICT、get DICT KEY
+func funcMap() template.FuncMap {
+	return template.FuncMap{
+		// 按 '-' 处理项目名，为兼容旧模板保留
+		"SkipFirstPart":        stringx.SkipFirstPart,
+		"SkipLastPart":         stringx.SkipLastPart,
+		"SkipFirstAndLastPart": stringx.SkipFirstAndLastParts,
+		"ToCamelCase":          stringx.ToCamelCase,
+		"ToPascalCase":         stringx.ToPascalCase,
+
+		// 命名转换
+		"pascalcase":    func(s string) string { return stringx.ToPascalCase(stringx.ToKebabCase(s)) },
+		"camelcase":     func(s string) string { return stringx.ToCamelCase(stringx.ToKebabCase(s)) },
+		"snakecase":     stringx.ToSnakeCase,
+		"kebabcase":     stringx.ToKebabCase,
+		"screamingcase": stringx.ToScreamingSnakeCase,
+		"dotcase":       stringx.ToDotCase,
+		"pathcase":      stringx.ToPathCase,
+		"upper":         strings.ToUpper,
+		"lower":         strings.ToLower,
+		"title":         stringx.Title,
+
+		// 字符串
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       func(sep string, list interface{}) string { return strings.Join(toStrings(list), sep) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"quote":      func(v interface{}) string { return fmt.Sprintf("%q", toString(v)) },
+		"repeat":     func(n int, s string) string { return strings.Repeat(s, n) },
+		"indent":     stringx.Indent,
+		"nindent":    stringx.NIndent,
+
+		// 逻辑
+		"default":  defaultValue,
+		"required": required,
+		"ternary":  ternary,
+		"empty":    isEmpty,
+		"coalesce": coalesce,
+
+		// 时间与随机
+		"now":          time.Now,
+		"year":         func() int { return time.Now().Year() },
+		"date":         func(layout string, t time.Time) string { return t.Format(layout) },
+		"randAlphaNum": stringx.RandomString,
+		"uuid":         stringx.UUID,
+
+		// 编码
+		"sha256sum":    stringx.SHA256,
+		"b64enc":       stringx.Base64Encode,
+		"b64dec":       stringx.Base64Decode,
+		"toYaml":       toYaml,
+		"toJson":       toJSON,
+		"toPrettyJson": toPrettyJSON,
+
+		// 列表与字典
+		"list":   func(items ...interface{}) []interface{} { return items },
+		"dict":   dict,
+		"append": func(list interface{}, item interface{}) []interface{} { return append(toInterfaces(list), item) },
+		"has":    has,
+		"first":  first,
+		"last":   last,
+		"uniq":   uniq,
+		"keys":   keys,
+		"get":    func(d map[string]interface{}, key string) interface{} { return d[key] },
+	}
+}
+
+// isEmpty 判断值是否为空：nil、零值、空字符串、空列表和空字典都视为空
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+func defaultValue(def interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || isEmpty(value[0]) {
+		return def
+	}
+	return value[0]
+}
+
+func required(msg string, value interface{}) (interface{}, error) {
+	if isEmpty(value) {
+		return nil, errors.New(msg)
+	}
+	return value, nil
+}
+
+func ternary(trueVal, falseVal interface{}, cond bool) interface{} {
+	if cond {
+		return trueVal
+	}
+	return falseVal
+}
+
+func coalesce(values ...interface{}) interface{} {
+	for _, v := range values {
+		if !isEmpty(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+func toYaml(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func toPrettyJSON(v interface{}) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	return string(b), err
+}
+
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict expects an even number of arguments")
+	}
+	d := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		d[toString(pairs[i])] = pairs[i+1]
+	}
+	return d, nil
+}
+
+func has(item interface{}, list interface{}) bool {
+	for _, v := range toInterfaces(list) {
+		if reflect.DeepEqual(v, item) {
+			return true
+		}
+	}
+	return false
+}
+
+func first(list interface{}) interface{} {
+	items := toInterfaces(list)
+	if len(items) == 0 {
+		return nil
+	}
+	return items[0]
+}
+
+func last(list interface{}) interface{} {
+	items := toInterfaces(list)
+	if len(items) == 0 {
+		return nil
+	}
+	return items[len(items)-1]
+}
+
+func uniq(list interface{}) []interface{} {
+	var res []interface{}
+	for _, v := range toInterfaces(list) {
+		if !has(v, res) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func keys(d interface{}) []string {
+	rv := reflect.ValueOf(d)
+	if rv.Kind() != reflect.Map {
+		return nil
+	}
+	res := make([]string, 0, rv.Len())
+	for _, k := range rv.MapKeys() {
+		res = append(res, fmt.Sprint(k.Interface()))
+	}
+	sort.Strings(res)
+	return res
+}
+
+// toInterfaces 将任意切片或数组转换为 []interface{}，其他值视为只有一个元素的列表
+func toInterfaces(list interface{}) []interface{} {
+	if list == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(list)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []interface{}{list}
+	}
+	res := make([]interface{}, rv.Len())
+	for i := range res {
+		res[i] = rv.Index(i).Interface()
+	}
+	return res
+}
+
+func toStrings(list interface{}) []string {
+	items := toInterfaces(list)
+	res := make([]string, len(items))
+	for i, v := range items {
+		res[i] = toString(v)
+	}
+	return res
+}
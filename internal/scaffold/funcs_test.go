@@ -0,0 +1,91 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// renderContent 通过 Renderer 渲染只包含一个文件的模板，返回渲染后的文件内容
+func renderContent(t *testing.T, content string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "out.txt"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data := TemplateData{
+		Name: "demo-app",
+		Vars: map[string]interface{}{
+			"team":    "payments",
+			"empty":   "",
+			"enabled": true,
+			"tags":    []interface{}{"web", "api"},
+			"created": time.Date(2023, 5, 17, 8, 30, 0, 0, time.UTC),
+		},
+	}
+	cfg := Config{Rules: []Rule{{Pattern: "out.txt", Action: ActionRender}}}
+	r, err := NewRenderer(cfg, &Manifest{}, dir, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := r.Render()
+	if err != nil {
+		return "", err
+	}
+	return files["/out.txt"].Content, nil
+}
+
+func TestFuncMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		want    string
+		wantErr string
+	}{
+		{"default on value", `{{ default "none" .Vars.team }}`, "payments", ""},
+		{"default on empty string", `{{ default "none" .Vars.empty }}`, "none", ""},
+		{"default on missing key", `{{ .Vars.missing | default "none" }}`, "none", ""},
+		{"default on false", `{{ default "on" false }}`, "on", ""},
+		{"required", `{{ required "team is required" .Vars.team }}`, "payments", ""},
+		{"required on empty string", `{{ required "empty is required" .Vars.empty }}`, "", "empty is required"},
+		{"required on missing key", `{{ .Vars.missing | required "missing is required" }}`, "", "missing is required"},
+		{"ternary true", `{{ ternary "yes" "no" .Vars.enabled }}`, "yes", ""},
+		{"ternary false", `{{ ternary "yes" "no" (eq .Name "other") }}`, "no", ""},
+		{"coalesce", `{{ coalesce .Vars.missing .Vars.empty .Vars.team "last" }}`, "payments", ""},
+		{"coalesce all empty", `{{ coalesce .Vars.missing .Vars.empty }}`, "<no value>", ""},
+		{"dict and get", `{{ get (dict "team" .Vars.team "app" .Name) "app" }}`, "demo-app", ""},
+		{"get missing key", `{{ get (dict "team" .Vars.team) "app" | default "none" }}`, "none", ""},
+		{"dict odd arguments", `{{ dict "team" }}`, "", "dict expects an even number of arguments"},
+		{"has", `{{ has "api" .Vars.tags }} {{ has "cli" .Vars.tags }}`, "true false", ""},
+		{"toYaml", `{{ dict "team" .Vars.team "tags" .Vars.tags | toYaml }}`, "tags:\n  - web\n  - api\nteam: payments", ""},
+		{"toJson", `{{ dict "team" .Vars.team "tags" .Vars.tags | toJson }}`, `{"tags":["web","api"],"team":"payments"}`, ""},
+		{"b64dec", `{{ "ZGVtby1hcHA=" | b64dec }}`, "demo-app", ""},
+		{"b64dec invalid input", `{{ "not base64!" | b64dec }}`, "", "illegal base64 data"},
+		{"date", `{{ date "2006-01-02 15:04" .Vars.created }}`, "2023-05-17 08:30", ""},
+	}
+	for _, tt := range tests {
+		got, err := renderContent(t, tt.tmpl)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want an error containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/imxw/gitlab-scaffold/internal/stringx"
+	"github.com/imxw/gitlab-scaffold/internal/values"
+)
+
+// ManifestFileName 是模板仓库根目录下的清单文件名
+const ManifestFileName = "scaffold.yaml"
+
+// 模板变量支持的类型
+const (
+	VarTypeString = "string"
+	VarTypeInt    = "int"
+	VarTypeBool   = "bool"
+	VarTypeEnum   = "enum"
+	VarTypeList   = "list"
+)
+
+// Manifest 描述模板仓库中 scaffold.yaml 的内容
+type Manifest struct {
+	Variables []Variable `yaml:"variables"`
+	// Rules 是模板自身的路径规则，在配置文件中的规则之后检查
+	Rules []Rule `yaml:"rules"`
+	// Paths 按变量决定是否包含某些文件或目录
+	Paths []Condition `yaml:"paths"`
+	// Delimiters 是模板的分隔符，如 ["[[", "]]"]，同时用于文件内容和路径名，默认为 {{ }}
+	Delimiters []string `yaml:"delimiters"`
+	// FileTypes 覆盖配置文件中的 extensions、base64_extensions 和 files
+	FileTypes `yaml:",inline"`
+	// Project 覆盖配置文件中的项目设置
+	Project ProjectConfig `yaml:"project"`
+}
+
+// Variable 描述模板声明的一个变量
+type Variable struct {
+	Name        string      `yaml:"name"`
+	Type        string      `yaml:"type"`
+	Default     interface{} `yaml:"default"`
+	Description string      `yaml:"description"`
+	Required    bool        `yaml:"required"`
+	// Pattern 是 string、enum 及 list 元素需要匹配的正则表达式
+	Pattern string `yaml:"pattern"`
+	// Min、Max 限定 int 的取值范围或 list 的元素个数
+	Min *int `yaml:"min"`
+	Max *int `yaml:"max"`
+	// Options 是 enum 类型的可选值
+	Options []string `yaml:"options"`
+}
+
+// LoadManifest 从模板根目录读取 scaffold.yaml，文件不存在时返回一个空清单
+func LoadManifest(rootPath string) (*Manifest, error) {
+	content, err := os.ReadFile(filepath.Join(rootPath, ManifestFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Manifest{}, nil
+		}
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err := yaml.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", ManifestFileName, err)
+	}
+
+	if err := m.check(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ManifestFileName, err)
+	}
+
+	return m, nil
+}
+
+// check 检查清单自身的声明是否合法
+func (m *Manifest) check() error {
+	if len(m.Delimiters) > 0 {
+		if err := checkDelimiters(m.Delimiters); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := range m.Variables {
+		v := &m.Variables[i]
+		if v.Name == "" {
+			return fmt.Errorf("variable #%d has no name", i+1)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("variable %s is declared more than once", v.Name)
+		}
+		seen[v.Name] = true
+
+		if v.Type == "" {
+			v.Type = VarTypeString
+		}
+		switch v.Type {
+		case VarTypeString, VarTypeInt, VarTypeBool, VarTypeList:
+		case VarTypeEnum:
+			if len(v.Options) == 0 {
+				return fmt.Errorf("enum variable %s has no options", v.Name)
+			}
+		default:
+			return fmt.Errorf("variable %s has unknown type %q", v.Name, v.Type)
+		}
+
+		if v.Pattern != "" {
+			if _, err := regexp.Compile(v.Pattern); err != nil {
+				return fmt.Errorf("variable %s has invalid pattern: %v", v.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Variable 按名称查找清单中声明的变量
+func (m *Manifest) Variable(name string) (*Variable, bool) {
+	for i := range m.Variables {
+		if m.Variables[i].Name == name {
+			return &m.Variables[i], true
+		}
+	}
+	return nil, false
+}
+
+// Resolve 用清单校验用户提供的变量值，补齐默认值并转换为声明的类型。
+// 名称中含有 . 的变量对应嵌套的值，与 --set db.type=mysql 一致。
+// 未在清单中声明的变量原样保留。所有校验错误会被合并后一起返回。
+func (m *Manifest) Resolve(supplied map[string]interface{}) (map[string]interface{}, error) {
+	vars := make(map[string]interface{}, len(supplied))
+	for k, v := range supplied {
+		vars[k] = v
+	}
+
+	var errs []error
+	for i := range m.Variables {
+		v := &m.Variables[i]
+
+		raw, ok := v.Lookup(vars)
+		if !ok || raw == nil {
+			if v.Default == nil {
+				if v.Required {
+					errs = append(errs, fmt.Errorf("variable %s is required", v.Name))
+				}
+				continue
+			}
+			raw = v.Default
+		}
+
+		value, err := v.Convert(raw)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := v.store(vars, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return vars, errors.Join(errs...)
+}
+
+// Lookup 在变量值中查找变量。名称本身不是键时，按 . 分隔的各段逐层查找嵌套的字典
+func (v *Variable) Lookup(vars map[string]interface{}) (interface{}, bool) {
+	if raw, ok := vars[v.Name]; ok || !strings.Contains(v.Name, ".") {
+		return raw, ok
+	}
+
+	var cur interface{} = vars
+	for _, key := range strings.Split(v.Name, ".") {
+		m, ok := asMap(cur)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// store 把转换后的值写回 vars。名称中含有 . 时写入嵌套的字典，模板中通过 .Vars.db.type 引用，
+// 沿途的字典会被复制，不修改用户提供的变量
+func (v *Variable) store(vars map[string]interface{}, value interface{}) error {
+	if !strings.Contains(v.Name, ".") {
+		vars[v.Name] = value
+		return nil
+	}
+	delete(vars, v.Name)
+
+	keys := strings.Split(v.Name, ".")
+	m := vars
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := asMap(m[key])
+		if !ok && m[key] != nil {
+			return fmt.Errorf("variable %s: %s is not a map", v.Name, key)
+		}
+		copied := make(values.Values, len(next)+1)
+		for k, val := range next {
+			copied[k] = val
+		}
+		m[key] = copied
+		m = copied
+	}
+	m[keys[len(keys)-1]] = value
+	return nil
+}
+
+// asMap 将 --set 和变量文件产生的嵌套字典转换为 map[string]interface{}
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case values.Values:
+		return m, true
+	case map[string]interface{}:
+		return m, true
+	}
+	return nil, false
+}
+
+// Convert 将原始值转换为变量声明的类型并进行校验
+func (v *Variable) Convert(raw interface{}) (interface{}, error) {
+	var value interface{}
+	var err error
+
+	switch v.Type {
+	case VarTypeInt:
+		value, err = toInt(raw)
+	case VarTypeBool:
+		value, err = toBool(raw)
+	case VarTypeList:
+		value, err = toList(raw)
+	default:
+		value = toString(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("variable %s: %v", v.Name, err)
+	}
+
+	if err := v.validate(value); err != nil {
+		return nil, fmt.Errorf("variable %s: %v", v.Name, err)
+	}
+	return value, nil
+}
+
+func (v *Variable) validate(value interface{}) error {
+	switch val := value.(type) {
+	case string:
+		if v.Required && val == "" {
+			return errors.New("must not be empty")
+		}
+		if v.Type == VarTypeEnum && !stringx.StringInSlice(val, v.Options) {
+			return fmt.Errorf("%q is not one of [%s]", val, strings.Join(v.Options, ", "))
+		}
+		return v.match(val)
+	case int:
+		return v.inRange(val, "value")
+	case []string:
+		if v.Required && len(val) == 0 {
+			return errors.New("must not be empty")
+		}
+		if err := v.inRange(len(val), "number of items"); err != nil {
+			return err
+		}
+		for _, item := range val {
+			if err := v.match(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *Variable) match(s string) error {
+	if v.Pattern == "" {
+		return nil
+	}
+	if !regexp.MustCompile(v.Pattern).MatchString(s) {
+		return fmt.Errorf("%q does not match pattern %s", s, v.Pattern)
+	}
+	return nil
+}
+
+func (v *Variable) inRange(n int, what string) error {
+	if v.Min != nil && n < *v.Min {
+		return fmt.Errorf("%s %d is less than %d", what, n, *v.Min)
+	}
+	if v.Max != nil && n > *v.Max {
+		return fmt.Errorf("%s %d is greater than %d", what, n, *v.Max)
+	}
+	return nil
+}
+
+func toString(raw interface{}) string {
+	if s, ok := raw.(string); ok {
+		return s
+	}
+	return fmt.Sprint(raw)
+}
+
+func toInt(raw interface{}) (int, error) {
+	switch val := raw.(type) {
+	case int:
+		return val, nil
+	case int64:
+		return int(val), nil
+	case float64:
+		if val != float64(int(val)) {
+			return 0, fmt.Errorf("%v is not an integer", val)
+		}
+		return int(val), nil
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an integer", val)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("%v is not an integer", raw)
+}
+
+func toBool(raw interface{}) (bool, error) {
+	switch val := raw.(type) {
+	case bool:
+		return val, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(val))
+		if err != nil {
+			return false, fmt.Errorf("%q is not a boolean", val)
+		}
+		return b, nil
+	}
+	return false, fmt.Errorf("%v is not a boolean", raw)
+}
+
+func toList(raw interface{}) ([]string, error) {
+	switch val := raw.(type) {
+	case []string:
+		return val, nil
+	case []interface{}:
+		list := make([]string, 0, len(val))
+		for _, item := range val {
+			list = append(list, toString(item))
+		}
+		return list, nil
+	case string:
+		if strings.TrimSpace(val) == "" {
+			return []string{}, nil
+		}
+		list := strings.Split(val, ",")
+		for i := range list {
+			list[i] = strings.TrimSpace(list[i])
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("%v is not a list", raw)
+}
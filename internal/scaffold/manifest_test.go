@@ -0,0 +1,83 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/imxw/gitlab-scaffold/internal/values"
+)
+
+func TestResolveDottedNames(t *testing.T) {
+	manifest := &Manifest{Variables: []Variable{
+		{Name: "db.type", Type: VarTypeEnum, Options: []string{"mysql", "postgres"}, Required: true},
+		{Name: "db.port", Type: VarTypeInt, Default: 3306},
+		{Name: "name", Default: "demo"},
+	}}
+
+	tests := []struct {
+		name     string
+		supplied values.Values
+		want     map[string]interface{}
+		wantErr  string
+	}{
+		{
+			name:     "nested values from --set",
+			supplied: values.Values{"db": values.Values{"type": "postgres", "port": "5432"}},
+			want:     map[string]interface{}{"db": values.Values{"type": "postgres", "port": 5432}, "name": "demo"},
+		},
+		{
+			name:     "default written as nested value",
+			supplied: values.Values{"db": values.Values{"type": "mysql"}},
+			want:     map[string]interface{}{"db": values.Values{"type": "mysql", "port": 3306}, "name": "demo"},
+		},
+		{
+			name:     "flat key moved to nested value",
+			supplied: values.Values{"db.type": "mysql"},
+			want:     map[string]interface{}{"db": values.Values{"type": "mysql", "port": 3306}, "name": "demo"},
+		},
+		{
+			name:     "missing nested value",
+			supplied: values.Values{"db": values.Values{"port": 5432}},
+			wantErr:  "variable db.type is required",
+		},
+		{
+			name:     "invalid nested value",
+			supplied: values.Values{"db": values.Values{"type": "oracle"}},
+			wantErr:  "variable db.type",
+		},
+		{
+			name:     "parent is not a map",
+			supplied: values.Values{"db": "mysql"},
+			wantErr:  "variable db.type is required",
+		},
+	}
+	for _, tt := range tests {
+		got, err := manifest.Resolve(tt.supplied)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want an error containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: vars = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// 用户提供的变量不被修改
+	supplied := values.Values{"db": values.Values{"type": "mysql"}}
+	manifest.Resolve(supplied)
+	if want := (values.Values{"db": values.Values{"type": "mysql"}}); !reflect.DeepEqual(supplied, want) {
+		t.Errorf("Resolve() modified its input: %v", supplied)
+	}
+}
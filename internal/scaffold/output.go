@@ -0,0 +1,73 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/imxw/gitlab-scaffold/internal/gitlabx"
+)
+
+// IsEmptyDir 判断目录是否为空，目录不存在时也视为空
+func IsEmptyDir(dir string) (bool, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	_, err = f.Readdirnames(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
+
+// WriteFiles 将渲染结果写入本地目录 dir，base64 编码的文件会先解码，文件权限与模板中保持一致。
+// 目录中已存在的同名文件会被覆盖。
+func WriteFiles(dir string, files map[string]*gitlabx.FileData) error {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	for path, file := range files {
+		target := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(path, "/")))
+		if !strings.HasPrefix(target, root+string(filepath.Separator)) {
+			return fmt.Errorf("refusing to write %s outside of %s", path, dir)
+		}
+
+		content, err := file.Decode()
+		if err != nil {
+			return fmt.Errorf("error decoding %s: %v", path, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		mode := file.Mode
+		if mode == 0 {
+			mode = 0644
+		}
+		if err := os.WriteFile(target, content, mode); err != nil {
+			return err
+		}
+		// os.WriteFile 不会修改已存在文件的权限
+		if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
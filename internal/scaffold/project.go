@@ -0,0 +1,217 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/imxw/gitlab-scaffold/internal/gitlabx"
+	"github.com/imxw/gitlab-scaffold/internal/stringx"
+)
+
+// 未配置分支策略时的默认值，与早期版本的行为一致
+const (
+	DefaultInitialBranch = "master"
+	DefaultBranch        = "dev"
+	DefaultCommitMessage = "init project from template {{ .Template }} [skip ci]"
+)
+
+// ProjectConfig 是新项目的设置，来自配置文件的 project 部分和模板清单的 project 部分，
+// 模板清单中设置的字段优先。
+type ProjectConfig struct {
+	// Visibility 为 private、internal 或 public，默认为 private
+	Visibility string   `mapstructure:"visibility" yaml:"visibility"`
+	Topics     []string `mapstructure:"topics" yaml:"topics"`
+	// Avatar 是模板中作为项目头像上传的图片，为渲染后相对模板根目录的路径
+	Avatar string `mapstructure:"avatar" yaml:"avatar"`
+	// InitializeWithReadme 为 true 时由 GitLab 创建 README.md，模板中的 README.md 会覆盖它
+	InitializeWithReadme *bool `mapstructure:"initialize_with_readme" yaml:"initialize_with_readme"`
+	// LFSEnabled 未设置时使用 GitLab 实例的默认值
+	LFSEnabled *bool `mapstructure:"lfs_enabled" yaml:"lfs_enabled"`
+
+	Branches BranchStrategy `mapstructure:"branches" yaml:"branches"`
+
+	// ProtectedBranches、ProtectedTags 和 Merge 在创建分支之后应用
+	ProtectedBranches []gitlabx.ProtectedBranch `mapstructure:"protected_branches" yaml:"protected_branches"`
+	ProtectedTags     []gitlabx.ProtectedTag    `mapstructure:"protected_tags" yaml:"protected_tags"`
+	Merge             gitlabx.MergeSettings     `mapstructure:"merge" yaml:"merge"`
+	// CopyProtection 为 true 时从模板项目复制受保护分支、受保护标签和合并设置，
+	// 同名的受保护分支或标签以及设置了的合并选项以这里的声明为准
+	CopyProtection *bool `mapstructure:"copy_protection" yaml:"copy_protection"`
+	// CopySettings 从模板项目复制允许的项目设置
+	CopySettings SettingsCopy `mapstructure:"copy_settings" yaml:"copy_settings"`
+}
+
+// SettingsCopy 描述从模板项目复制哪些设置。Fields 为 GitLab API 中的字段名，
+// 如 ci_config_path、build_timeout、wiki_access_level，为空时复制所有支持的设置。
+type SettingsCopy struct {
+	Enabled *bool    `mapstructure:"enabled" yaml:"enabled"`
+	Fields  []string `mapstructure:"fields" yaml:"fields"`
+}
+
+// IsEnabled 判断是否需要复制设置
+func (s SettingsCopy) IsEnabled() bool {
+	return s.Enabled != nil && *s.Enabled
+}
+
+// BranchStrategy 描述新项目的分支：模板文件提交到 Initial 分支，
+// 然后从它创建 Extra 中的分支，最后把 Default 设为默认分支。
+//
+// CommitMessage 是初始提交的提交信息，可以使用与文件内容相同的模板变量和函数，
+// 例如 {{ .Template }} 是模板名称和版本。
+type BranchStrategy struct {
+	Initial       string   `mapstructure:"initial" yaml:"initial"`
+	Extra         []string `mapstructure:"extra" yaml:"extra"`
+	Default       string   `mapstructure:"default" yaml:"default"`
+	CommitMessage string   `mapstructure:"commit_message" yaml:"commit_message"`
+}
+
+// DefaultProjectConfig 返回内置的项目设置：提交到 master，创建 dev 并设为默认分支
+func DefaultProjectConfig() ProjectConfig {
+	return ProjectConfig{
+		Branches: BranchStrategy{
+			Initial:       DefaultInitialBranch,
+			Extra:         []string{DefaultBranch},
+			Default:       DefaultBranch,
+			CommitMessage: DefaultCommitMessage,
+		},
+	}
+}
+
+// Override 用 o 中设置了的字段覆盖 c，返回合并后的设置，
+// 列表设置了时整体替换。
+func (c ProjectConfig) Override(o ProjectConfig) ProjectConfig {
+	if o.Visibility != "" {
+		c.Visibility = o.Visibility
+	}
+	if o.Topics != nil {
+		c.Topics = o.Topics
+	}
+	if o.Avatar != "" {
+		c.Avatar = o.Avatar
+	}
+	if o.InitializeWithReadme != nil {
+		c.InitializeWithReadme = o.InitializeWithReadme
+	}
+	if o.LFSEnabled != nil {
+		c.LFSEnabled = o.LFSEnabled
+	}
+	c.Branches = c.Branches.Override(o.Branches)
+	if o.ProtectedBranches != nil {
+		c.ProtectedBranches = o.ProtectedBranches
+	}
+	if o.ProtectedTags != nil {
+		c.ProtectedTags = o.ProtectedTags
+	}
+	c.Merge = c.Merge.Override(o.Merge)
+	if o.CopyProtection != nil {
+		c.CopyProtection = o.CopyProtection
+	}
+	if o.CopySettings.Enabled != nil {
+		c.CopySettings.Enabled = o.CopySettings.Enabled
+	}
+	if o.CopySettings.Fields != nil {
+		c.CopySettings.Fields = o.CopySettings.Fields
+	}
+	return c
+}
+
+// Check 检查项目设置是否合法
+func (c ProjectConfig) Check() error {
+	if err := gitlabx.CheckVisibility(c.Visibility); err != nil {
+		return err
+	}
+	if err := c.Branches.Check(); err != nil {
+		return err
+	}
+	for _, b := range c.ProtectedBranches {
+		if b.Name == "" {
+			return fmt.Errorf("protected branch has no name")
+		}
+		if err := gitlabx.CheckAccessLevel(b.PushAccessLevel); err != nil {
+			return fmt.Errorf("protected branch %s: %v", b.Name, err)
+		}
+		if err := gitlabx.CheckAccessLevel(b.MergeAccessLevel); err != nil {
+			return fmt.Errorf("protected branch %s: %v", b.Name, err)
+		}
+	}
+	for _, t := range c.ProtectedTags {
+		if t.Name == "" {
+			return fmt.Errorf("protected tag has no name")
+		}
+		if err := gitlabx.CheckAccessLevel(t.CreateAccessLevel); err != nil {
+			return fmt.Errorf("protected tag %s: %v", t.Name, err)
+		}
+	}
+	if err := gitlabx.CheckProjectSettingFields(c.CopySettings.Fields); err != nil {
+		return err
+	}
+	return c.Merge.Check()
+}
+
+// Override 用 o 中设置了的字段覆盖 b。Extra 设为空列表表示不创建其他分支。
+// o 没有设置 Default 时，继承的 Default 如果是被替换的 Initial 或者不在新的 Extra 中，
+// 会被清空，在所有设置合并之后由 Resolve 确定为 Initial。
+func (b BranchStrategy) Override(o BranchStrategy) BranchStrategy {
+	if o.Initial != "" {
+		if b.Default == b.Initial {
+			b.Default = ""
+		}
+		b.Initial = o.Initial
+	}
+	if o.Extra != nil {
+		b.Extra = o.Extra
+		if !stringx.StringInSlice(b.Default, b.Extra) {
+			b.Default = ""
+		}
+	}
+	if o.Default != "" {
+		b.Default = o.Default
+	}
+	if o.CommitMessage != "" {
+		b.CommitMessage = o.CommitMessage
+	}
+	return b
+}
+
+// Resolve 在所有设置合并之后确定默认分支，没有设置 Default 时默认分支为 Initial
+func (b BranchStrategy) Resolve() BranchStrategy {
+	if b.Default == "" {
+		b.Default = b.Initial
+	}
+	return b
+}
+
+// Check 检查默认分支是初始分支或需要创建的分支之一，并且需要创建的分支不包含初始分支
+func (b BranchStrategy) Check() error {
+	if b.Initial == "" {
+		return fmt.Errorf("initial branch is empty")
+	}
+	if stringx.StringInSlice(b.Initial, b.Extra) {
+		return fmt.Errorf("extra branches must not include the initial branch %q", b.Initial)
+	}
+	b = b.Resolve()
+	if b.Default != b.Initial && !stringx.StringInSlice(b.Default, b.Extra) {
+		return fmt.Errorf("default branch %q is neither the initial branch %q nor one of the extra branches", b.Default, b.Initial)
+	}
+	return nil
+}
+
+// Message 渲染初始提交的提交信息
+func (b BranchStrategy) Message(data TemplateData) (string, error) {
+	tmpl, err := template.New("commit_message").Funcs(funcMap()).Parse(b.CommitMessage)
+	if err != nil {
+		return "", fmt.Errorf("invalid commit message: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid commit message: %v", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBranchStrategyOverride(t *testing.T) {
+	builtin := DefaultProjectConfig().Branches
+
+	tests := []struct {
+		name    string
+		layers  []BranchStrategy
+		want    BranchStrategy
+		wantErr string
+	}{
+		{
+			name: "builtin",
+			want: BranchStrategy{Initial: "master", Extra: []string{"dev"}, Default: "dev"},
+		},
+		{
+			name:   "trunk based",
+			layers: []BranchStrategy{{Extra: []string{}}},
+			want:   BranchStrategy{Initial: "master", Extra: []string{}, Default: "master"},
+		},
+		{
+			name:   "initial changed after trunk based",
+			layers: []BranchStrategy{{Extra: []string{}}, {Initial: "main"}},
+			want:   BranchStrategy{Initial: "main", Extra: []string{}, Default: "main"},
+		},
+		{
+			name:   "initial changed after explicit default",
+			layers: []BranchStrategy{{Initial: "main", Extra: []string{}, Default: "main"}, {Initial: "trunk"}},
+			want:   BranchStrategy{Initial: "trunk", Extra: []string{}, Default: "trunk"},
+		},
+		{
+			name:   "extra changed without default",
+			layers: []BranchStrategy{{Initial: "main", Extra: []string{"develop"}, Default: "develop"}, {Extra: []string{"staging"}}},
+			want:   BranchStrategy{Initial: "main", Extra: []string{"staging"}, Default: "main"},
+		},
+		{
+			name:   "default kept when still created",
+			layers: []BranchStrategy{{Extra: []string{"dev", "staging"}}, {Initial: "main"}},
+			want:   BranchStrategy{Initial: "main", Extra: []string{"dev", "staging"}, Default: "dev"},
+		},
+		{
+			name:    "unknown default",
+			layers:  []BranchStrategy{{Default: "release"}},
+			wantErr: `default branch "release"`,
+		},
+		{
+			name:    "extra includes initial",
+			layers:  []BranchStrategy{{Initial: "main", Extra: []string{"main", "dev"}}},
+			wantErr: `must not include the initial branch "main"`,
+		},
+	}
+	for _, tt := range tests {
+		b := builtin
+		for _, layer := range tt.layers {
+			b = b.Override(layer)
+		}
+		b = b.Resolve()
+		b.CommitMessage = ""
+
+		err := b.Check()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: Check() error = %v, want an error containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Check() error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(b, tt.want) {
+			t.Errorf("%s: branches = %+v, want %+v", tt.name, b, tt.want)
+		}
+	}
+}
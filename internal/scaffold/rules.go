@@ -0,0 +1,248 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFileName 是模板仓库根目录下的规则文件名，语法与 .gitignore 类似
+const IgnoreFileName = ".glfastignore"
+
+// 规则对匹配路径采取的动作
+const (
+	// ActionRender 按模板渲染文件，忽略扩展名
+	ActionRender = "render"
+	// ActionCopy 原样复制文件，不做渲染
+	ActionCopy = "copy"
+	// ActionBinary 按 base64 编码提交文件
+	ActionBinary = "binary"
+	// ActionExclude 不把文件或目录提交到新项目
+	ActionExclude = "exclude"
+	// ActionInclude 取消之前的规则，按扩展名决定如何处理
+	ActionInclude = "include"
+)
+
+// Rule 是一条按 glob 匹配路径的规则。多条规则按顺序检查，最后一条匹配的规则生效。
+//
+// Pattern 的写法与 .gitignore 一致：不含 / 的模式匹配任意层级的文件或目录名，
+// 含 / 的模式相对模板根目录匹配，以 / 结尾的模式只匹配目录，
+// * 匹配除 / 以外的任意字符，** 匹配任意层级的目录。
+// 规则匹配一个目录时，对目录下的所有文件同样生效。
+//
+// Delimiters 可以为匹配的文件和目录指定模板分隔符，如 ["[[", "]]"]，
+// 同时用于文件内容和路径名。
+// 只设置 Delimiters 而不设置 Action 的规则不改变文件的处理方式。
+type Rule struct {
+	Pattern    string   `mapstructure:"pattern" yaml:"pattern"`
+	Action     string   `mapstructure:"action" yaml:"action"`
+	Delimiters []string `mapstructure:"delimiters" yaml:"delimiters"`
+}
+
+// compiledRule 是编译后的 Rule
+type compiledRule struct {
+	Rule
+	glob
+}
+
+// glob 是编译后的 gitignore 风格模式
+type glob struct {
+	re      *regexp.Regexp
+	dirOnly bool
+}
+
+// Rules 是一组按顺序检查的规则
+type Rules []compiledRule
+
+// CompileRules 编译规则，规则的 action 或 pattern 不合法时返回错误
+func CompileRules(rules []Rule) (Rules, error) {
+	compiled := make(Rules, 0, len(rules))
+	for _, rule := range rules {
+		if !isAction(rule.Action) && !(rule.Action == "" && len(rule.Delimiters) > 0) {
+			return nil, fmt.Errorf("rule %q has unknown action %q", rule.Pattern, rule.Action)
+		}
+		if len(rule.Delimiters) > 0 {
+			if err := checkDelimiters(rule.Delimiters); err != nil {
+				return nil, fmt.Errorf("rule %q: %v", rule.Pattern, err)
+			}
+		}
+
+		g, err := compileGlob(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", rule.Pattern, err)
+		}
+		compiled = append(compiled, compiledRule{Rule: rule, glob: g})
+	}
+	return compiled, nil
+}
+
+// Match 返回最后一条匹配 path 且设置了动作的规则的动作，以及最后一条匹配 path
+// 且设置了分隔符的规则的分隔符，没有匹配时返回零值。
+// path 是相对模板根目录、以 / 分隔的路径，isDir 表示 path 是否为目录。
+func (rs Rules) Match(path string, isDir bool) (string, []string) {
+	action := ""
+	var delims []string
+	for _, r := range rs {
+		if !r.matches(path, isDir) {
+			continue
+		}
+		if r.Action != "" {
+			action = r.Action
+		}
+		if len(r.Delimiters) > 0 {
+			delims = r.Delimiters
+		}
+	}
+	return action, delims
+}
+
+// hasInclude 判断是否有 include 规则
+func (rs Rules) hasInclude() bool {
+	for _, r := range rs {
+		if r.Action == ActionInclude {
+			return true
+		}
+	}
+	return false
+}
+
+// matches 判断模式是否匹配 path 本身或它的任意一级上级目录
+func (g glob) matches(path string, isDir bool) bool {
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	if (isDir || !g.dirOnly) && g.re.MatchString(path) {
+		return true
+	}
+	for i := strings.LastIndexByte(path, '/'); i > 0; i = strings.LastIndexByte(path, '/') {
+		path = path[:i]
+		if g.re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseIgnoreFile 解析 .glfastignore 的内容。每行一个模式：
+//
+//	# 注释
+//	docs/internal/        默认动作为 exclude
+//	!docs/internal/a.md   以 ! 开头表示 include
+//	copy: charts/**       以 动作: 开头指定其他动作
+func ParseIgnoreFile(content []byte) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := Rule{Action: ActionExclude, Pattern: line}
+		if strings.HasPrefix(line, "!") {
+			rule = Rule{Action: ActionInclude, Pattern: strings.TrimSpace(line[1:])}
+		} else if action, pattern, ok := strings.Cut(line, ":"); ok && isAction(strings.TrimSpace(action)) {
+			rule = Rule{Action: strings.TrimSpace(action), Pattern: strings.TrimSpace(pattern)}
+		}
+
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("%s:%d: empty pattern", IgnoreFileName, n)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// loadIgnoreFile 读取模板根目录下的 .glfastignore，文件不存在时返回 nil
+func loadIgnoreFile(rootPath string) ([]Rule, error) {
+	content, err := os.ReadFile(filepath.Join(rootPath, IgnoreFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ParseIgnoreFile(content)
+}
+
+func isAction(s string) bool {
+	switch s {
+	case ActionRender, ActionCopy, ActionBinary, ActionExclude, ActionInclude:
+		return true
+	}
+	return false
+}
+
+// compileGlob 将 gitignore 风格的模式编译为正则表达式
+func compileGlob(pattern string) (glob, error) {
+	if pattern == "" {
+		return glob{}, fmt.Errorf("empty pattern")
+	}
+
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	// 不含 / 的模式可以匹配任意层级
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				switch {
+				case i+1 < len(pattern) && pattern[i+1] == '/':
+					// **/ 匹配零到多级目录
+					i++
+					b.WriteString("(?:.*/)?")
+				default:
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return glob{}, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return glob{}, err
+	}
+	return glob{re: re, dirOnly: dirOnly}, nil
+}
@@ -0,0 +1,175 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGlobMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.tmp", "a.tmp", false, true},
+		{"*.tmp", "src/deep/a.tmp", false, true},
+		{"*.tmp", "a.tmpl", false, false},
+		{"docs/internal/", "docs/internal", true, true},
+		{"docs/internal/", "docs/internal/a.md", false, true},
+		{"docs/internal/", "docs/internal", false, false},
+		{"docs/internal/", "src/docs/internal", true, false},
+		{"internal/", "src/internal/a.go", false, true},
+		{"/README.md", "README.md", false, true},
+		{"/README.md", "docs/README.md", false, false},
+		{"docs/*.md", "docs/a.md", false, true},
+		{"docs/*.md", "docs/sub/a.md", false, false},
+		{"charts/**", "charts/app/templates/a.yaml", false, true},
+		{"**/testdata", "a/b/testdata/x.json", false, true},
+		{"**/testdata", "testdata", true, true},
+		{"web/**/*.vue", "web/App.vue", false, true},
+		{"web/**/*.vue", "web/src/components/App.vue", false, true},
+		{"web/**/*.vue", "api/App.vue", false, false},
+		{"file?.txt", "file1.txt", false, true},
+		{"file?.txt", "file10.txt", false, false},
+		{"[ab].txt", "a.txt", false, true},
+		{"[!ab].txt", "a.txt", false, false},
+		{"[!ab].txt", "c.txt", false, true},
+		{`\*.txt`, "*.txt", false, true},
+		{`\*.txt`, "a.txt", false, false},
+	}
+	for _, tt := range tests {
+		g, err := compileGlob(tt.pattern)
+		if err != nil {
+			t.Errorf("compileGlob(%q) error: %v", tt.pattern, err)
+			continue
+		}
+		if got := g.matches(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("%q matches %q (dir %t) = %t, want %t", tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{"", "[abc"} {
+		if _, err := compileGlob(pattern); err == nil {
+			t.Errorf("compileGlob(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestParseIgnoreFile(t *testing.T) {
+	content := `
+# comment
+docs/internal/
+!docs/internal/README.md
+copy: charts/**
+binary: *.keystore
+render : *.conf
+weird: name.txt
+`
+	rules, err := ParseIgnoreFile([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{
+		{Pattern: "docs/internal/", Action: ActionExclude},
+		{Pattern: "docs/internal/README.md", Action: ActionInclude},
+		{Pattern: "charts/**", Action: ActionCopy},
+		{Pattern: "*.keystore", Action: ActionBinary},
+		{Pattern: "*.conf", Action: ActionRender},
+		{Pattern: "weird: name.txt", Action: ActionExclude},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("ParseIgnoreFile() = %+v, want %+v", rules, want)
+	}
+
+	for _, content := range []string{"!", "copy:"} {
+		if _, err := ParseIgnoreFile([]byte(content)); err == nil || !strings.Contains(err.Error(), "empty pattern") {
+			t.Errorf("ParseIgnoreFile(%q) error = %v, want an empty pattern error", content, err)
+		}
+	}
+}
+
+func TestRulesMatch(t *testing.T) {
+	rules, err := ParseIgnoreFile([]byte(`
+docs/internal/
+!docs/internal/README.md
+*.tmp
+!keep.tmp
+copy: charts/**
+binary: *.keystore
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules = append([]Rule{{Pattern: "web/", Delimiters: []string{"<%", "%>"}}}, rules...)
+	compiled, err := CompileRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path       string
+		isDir      bool
+		wantAction string
+		wantDelims []string
+	}{
+		{"docs/internal", true, ActionExclude, nil},
+		{"docs/internal/notes.md", false, ActionExclude, nil},
+		{"docs/internal/README.md", false, ActionInclude, nil},
+		{"docs/public.md", false, "", nil},
+		{"build/a.tmp", false, ActionExclude, nil},
+		{"build/keep.tmp", false, ActionInclude, nil},
+		{"charts/app/values.yaml", false, ActionCopy, nil},
+		{"charts/app/release.keystore", false, ActionBinary, nil},
+		{"web/App.vue", false, "", []string{"<%", "%>"}},
+		{"web/old.tmp", false, ActionExclude, []string{"<%", "%>"}},
+	}
+	for _, tt := range tests {
+		action, delims := compiled.Match(tt.path, tt.isDir)
+		if action != tt.wantAction || !reflect.DeepEqual(delims, tt.wantDelims) {
+			t.Errorf("Match(%q) = %q, %q, want %q, %q", tt.path, action, delims, tt.wantAction, tt.wantDelims)
+		}
+	}
+
+	for _, rule := range []Rule{{Pattern: "a", Action: "delete"}, {Pattern: "a"}, {Pattern: "a", Delimiters: []string{"<%"}}} {
+		if _, err := CompileRules([]Rule{rule}); err == nil {
+			t.Errorf("CompileRules(%+v) succeeded, want an error", rule)
+		}
+	}
+}
+
+func TestRenderIgnoreFile(t *testing.T) {
+	dir := writeTree(t, "README.md", "docs/internal/notes.md", "docs/internal/README.md", "docs/internal/old/a.md", "build/a.tmp", "build/keep.tmp")
+	ignore := "docs/internal/\n!docs/internal/README.md\n*.tmp\n!keep.tmp\n"
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFileName), []byte(ignore), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRenderer(Config{}, &Manifest{}, dir, TemplateData{Name: "demo-app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := r.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for p := range files {
+		got = append(got, p)
+	}
+	sort.Strings(got)
+	want := []string{"/README.md", "/build/keep.tmp", "/docs/internal/README.md"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rendered files = %q, want %q", got, want)
+	}
+}
@@ -7,8 +7,10 @@ package scaffold
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -24,6 +26,12 @@ const (
 	DefaultTemplateGroup = "template"
 )
 
+// 默认的模板分隔符
+const (
+	defaultLeftDelim  = "{{"
+	defaultRightDelim = "}}"
+)
+
 var defaultExtensions = []string{
 	".go", ".java", ".py", ".vue", // Source code files
 	".md",                           // Documentation files
@@ -47,35 +55,176 @@ var defaultFiles = []string{
 type TemplateData struct {
 	Name string
 	Port int
+	// Template 是生成项目所用模板的名称和版本
+	Template TemplateInfo
+	// Vars 是按模板清单校验后的变量，模板中通过 .Vars.xxx 引用
+	Vars map[string]interface{}
+}
+
+// TemplateInfo 记录模板的名称和版本，模板中可以通过 .Template.SHA 等引用
+type TemplateInfo struct {
+	Name string
+	// Ref 是用户指定的分支、标签或提交，为空表示默认分支
+	Ref string
+	// SHA 是 Ref 解析得到的完整提交 SHA
+	SHA string
+	// Dir 是本地模板目录，模板来自 GitLab 时为空
+	Dir string
+}
+
+// String 返回 name@sha 形式的模板版本，本地模板返回 name (local template)。
+// 它会写入新项目的提交信息，所以不包含本地目录的路径。
+func (t TemplateInfo) String() string {
+	if t.Dir != "" {
+		return t.Name + " (local template)"
+	}
+	if t.SHA == "" {
+		return t.Name
+	}
+	return t.Name + "@" + t.SHA
+}
+
+// Location 返回模板版本，本地模板返回 name (dir)，只用于本地输出
+func (t TemplateInfo) Location() string {
+	if t.Dir != "" {
+		return fmt.Sprintf("%s (%s)", t.Name, t.Dir)
+	}
+	return t.String()
+}
+
+// ParseTemplateRef 解析 TEMPLATE@REF 形式的模板参数
+func ParseTemplateRef(arg string) (name, ref string) {
+	name, ref, _ = strings.Cut(arg, "@")
+	return name, ref
 }
 
 type Config struct {
-	Namespace        string   `mapstructure:"namespace"`
-	Extensions       []string `mapstructure:"extensions"`
-	Base64Extensions []string `mapstructure:"base64_extensions"`
-	Files            []string `mapstructure:"files"`
+	Namespace string    `mapstructure:"namespace"`
+	FileTypes FileTypes `mapstructure:",squash"`
+	// Rules 是对所有模板生效的路径规则，先于模板自身的规则检查
+	Rules []Rule `mapstructure:"rules"`
+}
+
+// Renderer 将解压后的模板目录渲染为待提交的文件
+type Renderer struct {
+	rootPath   string
+	data       TemplateData
+	types      FileTypes
+	rules      Rules
+	conditions Conditions
+	// delims 是模板级别的分隔符，规则可以为部分文件另行指定
+	delims []string
+	// sources 记录渲染后的路径对应的模板路径，用于发现路径冲突
+	sources map[string]string
 }
 
-func replacePathName(serviceName, pathName string) string {
+// NewRenderer 创建一个 Renderer。文件类型列表以内置默认值为基础，
+// 依次应用配置文件和模板清单中的设置。路径规则按配置文件、模板清单、
+// .glfastignore 的顺序排列，最后一条匹配的规则生效。
+func NewRenderer(cfg Config, manifest *Manifest, rootPath string, data TemplateData) (*Renderer, error) {
+	types, err := DefaultFileTypes().Override(cfg.FileTypes)
+	if err != nil {
+		return nil, fmt.Errorf("template config: %v", err)
+	}
+
+	types, err = types.Override(manifest.FileTypes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ManifestFileName, err)
+	}
+
+	ignoreRules, err := loadIgnoreFile(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []Rule
+	all = append(all, cfg.Rules...)
+	all = append(all, manifest.Rules...)
+	all = append(all, ignoreRules...)
+	rules, err := CompileRules(all)
+	if err != nil {
+		return nil, err
+	}
 
+	conditions, err := CompileConditions(manifest.Paths)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ManifestFileName, err)
+	}
+
+	delims := []string{defaultLeftDelim, defaultRightDelim}
+	if len(manifest.Delimiters) > 0 {
+		delims = manifest.Delimiters
+	}
+
+	return &Renderer{
+		rootPath:   rootPath,
+		data:       data,
+		types:      types,
+		rules:      rules,
+		conditions: conditions,
+		delims:     delims,
+	}, nil
+}
+
+// Render 遍历模板目录，返回以新项目中的路径为键的文件集合
+func (r *Renderer) Render() (map[string]*gitlabx.FileData, error) {
+	fileMap := make(map[string]*gitlabx.FileData)
+	r.sources = make(map[string]string)
+
+	err := filepath.Walk(r.rootPath, func(path string, info os.FileInfo, err error) error {
+		visitFileMap, err := r.VisitAndModifyFiles(path, info, err)
+		if err == filepath.SkipDir {
+			return err
+		}
+		if err != nil {
+			fmt.Printf("error visiting and modifying files in %v: %v\n", path, err)
+			return err
+		}
+
+		for k, v := range visitFileMap {
+			fileMap[k] = v
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fileMap, nil
+}
+
+// checkDelimiters 检查分隔符是否为一对非空字符串
+func checkDelimiters(delims []string) error {
+	if len(delims) != 2 || delims[0] == "" || delims[1] == "" {
+		return fmt.Errorf("delimiters must be a pair of non-empty strings, got %q", delims)
+	}
+	return nil
+}
+
+// replacePathName 替换路径中 {{Name_ToPascalCase}} 等固定写法的占位符。
+// 这些写法早于路径模板出现，为了兼容旧模板仍然保留。
+func replacePathName(serviceName, pathName string, delims []string) string {
+
+	left, right := delims[0], delims[1]
 	replacements := map[string]string{
-		"{{Name}}":                      serviceName,
-		"{{Name_SkipFirstPart}}":        stringx.SkipFirstPart(serviceName),
-		"{{Name_SkipFirstAndLastPart}}": stringx.SkipFirstAndLastParts(serviceName),
-		"{{Name_ToPascalCase}}":         stringx.ToPascalCase(serviceName),
-		"{{Name_ToCamelCase}}":          stringx.ToCamelCase(serviceName),
+		"Name":                      serviceName,
+		"Name_SkipFirstPart":        stringx.SkipFirstPart(serviceName),
+		"Name_SkipFirstAndLastPart": stringx.SkipFirstAndLastParts(serviceName),
+		"Name_ToPascalCase":         stringx.ToPascalCase(serviceName),
+		"Name_ToCamelCase":          stringx.ToCamelCase(serviceName),
 	}
 
 	for k, v := range replacements {
-		pathName = strings.Replace(pathName, k, v, -1)
+		pathName = strings.Replace(pathName, left+k+right, v, -1)
 	}
 
 	return pathName
 }
 
-func ListTemplates(client *gitlabx.Client) (string, error) {
+func ListTemplates(ctx context.Context, client *gitlabx.Client) (string, error) {
 
-	projects, err := client.ListProjectsInGroup(DefaultTemplateGroup)
+	projects, err := client.ListProjectsInGroup(ctx, DefaultTemplateGroup)
 	if err != nil {
 		return "", err
 	}
@@ -87,31 +236,112 @@ func ListTemplates(client *gitlabx.Client) (string, error) {
 }
 
 // DownloadAndUnpackTemplateToTempDir 是一个函数，它下载指定模板并将其解压到临时目录，
-// 然后返回解压后数据的路径。它接收两个参数：一个 gitlabx.Client 实例和一个字符串模板名。
-// 它返回解压后的数据路径及错误信息。
-func DownloadAndUnpackTemplateToTempDir(client *gitlabx.Client, templateName string) (string, error) {
+// 然后返回解压后数据的路径。它接收四个参数：请求使用的 context、一个 gitlabx.Client 实例、一个字符串模板名和模板版本。
+// 版本可以是分支、标签或提交 SHA，为空时使用默认分支；它会先被解析为提交 SHA，
+// 再按该 SHA 下载，保证解压出的内容与返回的 SHA 一致。
+// 它返回解压后的数据路径、模板信息及错误信息。
+func DownloadAndUnpackTemplateToTempDir(ctx context.Context, client *gitlabx.Client, templateName, ref string) (string, TemplateInfo, error) {
 
-	data, err := client.GetProjectArchive(DefaultTemplateGroup + "/" + templateName)
+	project := DefaultTemplateGroup + "/" + templateName
+	info := TemplateInfo{Name: templateName, Ref: ref}
+
+	sha, err := client.ResolveRef(ctx, project, ref)
 	if err != nil {
-		return "", err
+		return "", info, err
+	}
+	info.SHA = sha
+
+	data, err := client.GetProjectArchive(ctx, project, sha)
+	if err != nil {
+		return "", info, err
 	}
 
 	// 创建一个临时目录来保存解压后的文件
 	tempDir, err := os.MkdirTemp("", "template")
 	if err != nil {
-		return "", err
+		return "", info, err
 	}
 
 	rootPath, err := util.UnpackTarGz(data, tempDir)
 	if err != nil {
-		return "", err
+		return "", info, err
 	}
 
-	return rootPath, nil
+	return rootPath, info, nil
 
 }
 
-func VisitAndModifyFiles(rootPath, path string, data TemplateData, f os.FileInfo, err error) (map[string]*gitlabx.FileData, error) {
+// renderPath 将相对模板根目录的路径按模板渲染，路径与文件内容使用相同的函数和数据。
+// 每一级按匹配它的规则选择分隔符：各级分隔符相同时整条路径一起渲染，表达式可以跨越多级，
+// 不同时逐级渲染。渲染后任意一级为空（如条件不成立）时返回空字符串，表示丢弃该路径。
+func (r *Renderer) renderPath(rel string, isDir bool) (string, error) {
+	parts := strings.Split(rel, "/")
+	delims := make([][]string, len(parts))
+	same := true
+	for i := range parts {
+		_, d := r.rules.Match(strings.Join(parts[:i+1], "/"), isDir || i < len(parts)-1)
+		if len(d) == 0 {
+			d = r.delims
+		}
+		delims[i] = d
+		same = same && d[0] == delims[0][0] && d[1] == delims[0][1]
+	}
+
+	if same {
+		rendered, err := r.renderPathTemplate(rel, delims[0])
+		if err != nil {
+			return "", err
+		}
+		rel = rendered
+	} else {
+		for i, part := range parts {
+			rendered, err := r.renderPathTemplate(part, delims[i])
+			if err != nil {
+				return "", err
+			}
+			parts[i] = rendered
+		}
+		rel = strings.Join(parts, "/")
+	}
+
+	for _, seg := range strings.Split(rel, "/") {
+		switch strings.TrimSpace(seg) {
+		case "":
+			return "", nil
+		case ".", "..":
+			return "", fmt.Errorf("path %q must not contain %q", rel, seg)
+		}
+	}
+	return rel, nil
+}
+
+// errUnclosedAction 表示路径以未闭合的模板表达式结尾，表达式在下一级路径中才结束
+var errUnclosedAction = errors.New("unclosed action")
+
+// renderPathTemplate 使用给定的分隔符渲染路径或路径中的一级
+func (r *Renderer) renderPathTemplate(path string, delims []string) (string, error) {
+	path = replacePathName(r.data.Name, path, delims)
+	if !strings.Contains(path, delims[0]) {
+		return path, nil
+	}
+	if strings.LastIndex(path, delims[0]) > strings.LastIndex(path, delims[1]) {
+		return "", fmt.Errorf("error parsing the path template %v: %w", path, errUnclosedAction)
+	}
+
+	tmpl, err := template.New(path).Delims(delims[0], delims[1]).Funcs(funcMap()).Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("error parsing the path template %v: %v", path, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, r.data); err != nil {
+		return "", fmt.Errorf("error executing the path template %v: %v", path, err)
+	}
+	return buf.String(), nil
+}
+
+func (r *Renderer) VisitAndModifyFiles(path string, f os.FileInfo, err error) (map[string]*gitlabx.FileData, error) {
+	rootPath, data := r.rootPath, r.data
 
 	fileMap := make(map[string]*gitlabx.FileData)
 
@@ -120,15 +350,80 @@ func VisitAndModifyFiles(rootPath, path string, data TemplateData, f os.FileInfo
 		return nil, err
 	}
 
-	newpath := replacePathName(data.Name, path)
+	rel, err := filepath.Rel(rootPath, path)
+	if err != nil {
+		return nil, err
+	}
+	if rel == "." {
+		return fileMap, nil
+	}
+	rel = filepath.ToSlash(rel)
+
+	// 模板清单和规则文件只用于渲染，不提交到新项目
+	if rel == ManifestFileName || rel == IgnoreFileName {
+		return fileMap, nil
+	}
+
+	// 本地模板目录可能是一个 git 仓库
+	if rel == ".git" {
+		if f.IsDir() {
+			return nil, filepath.SkipDir
+		}
+		return fileMap, nil
+	}
+
+	action, delims := r.rules.Match(rel, f.IsDir())
+	if len(delims) == 0 {
+		delims = r.delims
+	}
+	if action == ActionExclude {
+		// 目录下的文件可能被 ! 规则重新包含，这时进入目录逐个检查其中的文件
+		if f.IsDir() && !r.rules.hasInclude() {
+			return nil, filepath.SkipDir
+		}
+		return fileMap, nil
+	}
+
+	// 条件不成立的文件不提交，目录整个跳过
+	include, err := r.conditions.Include(rel, f.IsDir(), data)
+	if err != nil {
+		return nil, err
+	}
+	if !include {
+		if f.IsDir() {
+			return nil, filepath.SkipDir
+		}
+		return fileMap, nil
+	}
+
+	rendered, err := r.renderPath(rel, f.IsDir())
+	if err != nil {
+		// 模板表达式中可以包含 /，如 {{ .Vars.package | replace "." "/" }}，
+		// 这时表达式跨越多级目录，到下一级才完整，目录本身无需渲染，其他错误直接返回
+		if f.IsDir() && errors.Is(err, errUnclosedAction) {
+			return fileMap, nil
+		}
+		return nil, err
+	}
+	if rendered == "" {
+		if f.IsDir() {
+			return nil, filepath.SkipDir
+		}
+		return fileMap, nil
+	}
+
+	newpath := "/" + rendered
 	base := filepath.Base(newpath)
 
-	newpath = strings.TrimPrefix(newpath, rootPath)
+	if src, ok := r.sources[newpath]; ok {
+		return nil, fmt.Errorf("%s and %s both render to %s", src, rel, newpath)
+	}
+	r.sources[newpath] = rel
+
 	encode := "text"
 	var fileContent string
 	if !f.IsDir() {
-		ext := filepath.Ext(newpath)
-		ext = strings.ToLower(ext)
+
 		var content []byte
 		content, err = os.ReadFile(path)
 
@@ -136,16 +431,17 @@ func VisitAndModifyFiles(rootPath, path string, data TemplateData, f os.FileInfo
 			return nil, err
 		}
 
-		if stringx.StringInSlice(ext, defaultExtensions) || stringx.StringInSlice(base, defaultFiles) {
+		switch {
+		case action == ActionBinary || r.types.IsBase64(base) || IsBinary(content):
+
+			// 二进制文件不渲染，按 base64 编码提交
+			fileContent = base64.StdEncoding.EncodeToString(content)
+			encode = "base64"
+
+		case action == ActionRender || (action != ActionCopy && r.types.IsTemplate(base)):
 
 			// Template processing
-			tmpl, err := template.New("content").Funcs(template.FuncMap{
-				"SkipFirstPart":        stringx.SkipFirstPart,
-				"SkipLastPart":         stringx.SkipLastPart,
-				"SkipFirstAndLastPart": stringx.SkipFirstAndLastParts,
-				"ToCamelCase":          stringx.ToCamelCase,
-				"ToPascalCase":         stringx.ToPascalCase,
-			}).Parse(string(content))
+			tmpl, err := template.New("content").Delims(delims[0], delims[1]).Funcs(funcMap()).Parse(string(content))
 			if err != nil {
 				fmt.Printf("error parsing the template %v: %v\n", path, err)
 				return nil, err
@@ -159,18 +455,14 @@ func VisitAndModifyFiles(rootPath, path string, data TemplateData, f os.FileInfo
 			}
 			fileContent = tpl.String()
 
-		} else if stringx.StringInSlice(ext, defaultBase64Extensions) {
-
-			fileContent = base64.StdEncoding.EncodeToString(content)
-			encode = "base64"
-
-		} else {
+		default:
 			fileContent = string(content)
 		}
 
 		fileMap[newpath] = &gitlabx.FileData{
 			Content:  fileContent,
 			Encoding: encode,
+			Mode:     f.Mode().Perm(),
 		}
 
 	}
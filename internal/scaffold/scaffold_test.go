@@ -0,0 +1,138 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRenderPathDelimiters(t *testing.T) {
+	manifest := &Manifest{
+		Delimiters: []string{"[[", "]]"},
+		Rules: []Rule{
+			{Pattern: "web/**/*.vue", Delimiters: []string{"<%", "%>"}},
+			{Pattern: "charts/", Delimiters: []string{"<<", ">>"}},
+		},
+	}
+	data := TemplateData{Name: "demo-app", Vars: map[string]interface{}{"package": "com.example"}}
+	r, err := NewRenderer(Config{}, manifest, t.TempDir(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  string
+	}{
+		{"[[ .Name ]]/README.md", false, "demo-app/README.md"},
+		{`src/[[ .Vars.package | replace "." "/" ]]/App.java`, false, "src/com/example/App.java"},
+		{"web/[[ .Name ]]/<% .Name %>.vue", false, "web/demo-app/demo-app.vue"},
+		{"web/[[ .Name ]]", true, "web/demo-app"},
+		{"web/<% .Name %>.js", false, "web/<% .Name %>.js"},
+		{"charts/<< .Name >>/values.yaml", false, "charts/demo-app/values.yaml"},
+		{"charts/[[Name_ToPascalCase]]", true, "charts/[[Name_ToPascalCase]]"},
+		{"[[Name_ToPascalCase]]/<<Name>>.txt", false, "DemoApp/<<Name>>.txt"},
+	}
+	for _, tt := range tests {
+		got, err := r.renderPath(tt.path, tt.isDir)
+		if err != nil {
+			t.Errorf("renderPath(%q) error: %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("renderPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+// writeTree 在临时目录中创建模板文件，内容为空
+func writeTree(t *testing.T, paths ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, p := range paths {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRenderDirectories(t *testing.T) {
+	data := TemplateData{Name: "demo-app", Vars: map[string]interface{}{"package": "com.example", "module": "api"}}
+
+	tests := []struct {
+		name    string
+		paths   []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "expression spanning directories",
+			paths: []string{`src/{{ .Vars.package | replace "." "/" }}/App.java`},
+			want:  []string{"/src/com/example/App.java"},
+		},
+		{
+			name:    "parse error in a directory",
+			paths:   []string{"{{ .Name | nosuchfunc }}/README.md"},
+			wantErr: `function "nosuchfunc" not defined`,
+		},
+		{
+			name:    "exec error in a directory",
+			paths:   []string{`{{ required "module is required" .Vars.missing }}/README.md`},
+			wantErr: "module is required",
+		},
+		{
+			name:    "directory rendering to ..",
+			paths:   []string{`{{ "." }}{{ "." }}/README.md`},
+			wantErr: `must not contain ".."`,
+		},
+		{
+			name:    "directories rendering to the same path",
+			paths:   []string{"{{ .Vars.module }}/a.txt", "api/b.txt"},
+			wantErr: "both render to /api",
+		},
+		{
+			name:    "file and directory rendering to the same path",
+			paths:   []string{"{{ .Vars.module }}", "api/b.txt"},
+			wantErr: "both render to /api",
+		},
+	}
+	for _, tt := range tests {
+		r, err := NewRenderer(Config{}, &Manifest{}, writeTree(t, tt.paths...), data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := r.Render()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want an error containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for p := range files {
+			got = append(got, p)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: files = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package scaffold
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/imxw/gitlab-scaffold/internal/gitlabx"
+)
+
+// Source 描述模板的来源：GitLab 上 template 组中的项目，或者本地目录。
+// 设置了 Dir 时直接使用本地目录，不会下载模板，便于模板开发时调试。
+type Source struct {
+	// Name 是模板名称，使用本地目录时可以为空，默认取目录名
+	Name string
+	// Ref 是模板的分支、标签或提交 SHA，仅对 GitLab 上的模板有效
+	Ref string
+	// Dir 是本地模板目录
+	Dir string
+}
+
+// IsLocal 判断模板是否来自本地目录
+func (s Source) IsLocal() bool {
+	return s.Dir != ""
+}
+
+// Fetch 准备好模板目录，返回模板根目录、模板信息，以及用于删除临时文件的清理函数。
+// 本地目录不会被复制或修改，清理函数不做任何事。
+func (s Source) Fetch(ctx context.Context, client *gitlabx.Client) (string, TemplateInfo, func(), error) {
+	noop := func() {}
+
+	if !s.IsLocal() {
+		rootPath, info, err := DownloadAndUnpackTemplateToTempDir(ctx, client, s.Name, s.Ref)
+		if err != nil {
+			return "", info, noop, err
+		}
+		// rootPath 是临时目录下的唯一子目录
+		tempDir := filepath.Dir(rootPath)
+		return rootPath, info, func() { os.RemoveAll(tempDir) }, nil
+	}
+
+	if s.Ref != "" {
+		return "", TemplateInfo{}, noop, fmt.Errorf("a template version cannot be used with a local template directory")
+	}
+
+	dir, err := filepath.Abs(s.Dir)
+	if err != nil {
+		return "", TemplateInfo{}, noop, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", TemplateInfo{}, noop, err
+	}
+	if !info.IsDir() {
+		return "", TemplateInfo{}, noop, fmt.Errorf("%s is not a directory", s.Dir)
+	}
+
+	name := s.Name
+	if name == "" {
+		name = filepath.Base(dir)
+	}
+
+	return dir, TemplateInfo{Name: name, Dir: dir}, noop, nil
+}
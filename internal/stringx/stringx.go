@@ -6,6 +6,12 @@ license that can be found in the LICENSE file.
 package stringx
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
 	"strings"
 	"unicode"
 )
@@ -78,7 +84,190 @@ func SkipFirstAndLastParts(s string) string {
 // 例如，"hello-world" 转换为 "helloWorld"
 func ToCamelCase(s string) string {
 	s = ToPascalCase(s)
+	if s == "" {
+		return s
+	}
 	r := []rune(s)
 	r[0] = unicode.ToLower(r[0])
 	return string(r)
 }
+
+// FuzzyMatch 函数判断 pattern 中的字符是否按顺序出现在 s 中（忽略大小写）
+// 例如，"tbe" 可以匹配 "team1/backend"
+func FuzzyMatch(pattern, s string) bool {
+	s = strings.ToLower(s)
+	for _, r := range strings.ToLower(pattern) {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+	return true
+}
+
+// FuzzyFind 函数返回 list 中与 pattern 模糊匹配的字符串
+// 包含 pattern 子串的结果排在前面，其余按原顺序排列
+func FuzzyFind(pattern string, list []string) []string {
+	var contains, others []string
+	lower := strings.ToLower(pattern)
+	for _, s := range list {
+		switch {
+		case strings.Contains(strings.ToLower(s), lower):
+			contains = append(contains, s)
+		case FuzzyMatch(pattern, s):
+			others = append(others, s)
+		}
+	}
+	return append(contains, others...)
+}
+
+// Words 函数将字符串拆分为单词，'-'、'_'、'.'、'/'、空白等非字母数字字符视为分隔符，
+// 小写字母到大写字母的位置以及连续大写字母的末尾也视为单词边界
+// 例如，"helloWorld_HTTPServer-v2" 拆分为 ["hello", "World", "HTTP", "Server", "v2"]
+func Words(s string) []string {
+	var words []string
+	var cur []rune
+	runes := []rune(s)
+
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = nil
+		}
+	}
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(cur) > 0 {
+			prev := cur[len(cur)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+
+	return words
+}
+
+// joinWords 将单词按 transform 转换后以 sep 连接
+func joinWords(s, sep string, transform func(string) string) string {
+	words := Words(s)
+	for i, w := range words {
+		words[i] = transform(w)
+	}
+	return strings.Join(words, sep)
+}
+
+// ToSnakeCase 函数将字符串转换为 snake_case
+// 例如，"helloWorld" 和 "hello-world" 都转换为 "hello_world"
+func ToSnakeCase(s string) string {
+	return joinWords(s, "_", strings.ToLower)
+}
+
+// ToKebabCase 函数将字符串转换为 kebab-case
+// 例如，"HelloWorld" 转换为 "hello-world"
+func ToKebabCase(s string) string {
+	return joinWords(s, "-", strings.ToLower)
+}
+
+// ToScreamingSnakeCase 函数将字符串转换为 SCREAMING_SNAKE_CASE
+// 例如，"hello-world" 转换为 "HELLO_WORLD"
+func ToScreamingSnakeCase(s string) string {
+	return joinWords(s, "_", strings.ToUpper)
+}
+
+// ToDotCase 函数将字符串转换为 dot.case
+// 例如，"hello-world" 转换为 "hello.world"
+func ToDotCase(s string) string {
+	return joinWords(s, ".", strings.ToLower)
+}
+
+// ToPathCase 函数将字符串转换为 path/case
+// 例如，"com.example.HelloWorld" 转换为 "com/example/hello/world"
+func ToPathCase(s string) string {
+	return joinWords(s, "/", strings.ToLower)
+}
+
+// Title 函数将字符串中每个单词的首字母转换为大写，单词之间的分隔符保持不变
+// 例如，"hello world-app" 转换为 "Hello World-App"
+func Title(s string) string {
+	runes := []rune(s)
+	start := true
+	for i, r := range runes {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start {
+				runes[i] = unicode.ToUpper(r)
+			}
+			start = false
+		} else {
+			start = true
+		}
+	}
+	return string(runes)
+}
+
+// Indent 函数在字符串每一行的开头添加 n 个空格
+func Indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// NIndent 函数与 Indent 相同，但会在结果前添加一个换行符，便于在 YAML 中嵌套内容
+func NIndent(n int, s string) string {
+	return "\n" + Indent(n, s)
+}
+
+// SHA256 函数返回字符串 SHA-256 摘要的十六进制表示
+func SHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// Base64Encode 函数返回字符串的标准 base64 编码
+func Base64Encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// Base64Decode 函数解码标准 base64 编码的字符串
+func Base64Decode(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	return string(b), err
+}
+
+const alphaNum = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// RandomString 函数使用加密安全的随机数生成长度为 n 的字母数字字符串，适合生成密钥等
+func RandomString(n int) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("invalid length %d", n)
+	}
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(alphaNum)))
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphaNum[idx.Int64()]
+	}
+	return string(b), nil
+}
+
+// UUID 函数生成一个随机的 (version 4) UUID
+// 例如，"3f2b8c1e-9d4a-4c6b-8e2f-1a2b3c4d5e6f"
+func UUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package stringx
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"hello", []string{"hello"}},
+		{"hello-world", []string{"hello", "world"}},
+		{"hello_world.app/v2", []string{"hello", "world", "app", "v2"}},
+		{"helloWorld", []string{"hello", "World"}},
+		{"HTTPServer", []string{"HTTP", "Server"}},
+		{"parseHTTPRequest2Fast", []string{"parse", "HTTP", "Request2", "Fast"}},
+		{"  spaced  out ", []string{"spaced", "out"}},
+	}
+	for _, tt := range tests {
+		if got := Words(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Words(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCaseConversions(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(string) string
+		in   string
+		want string
+	}{
+		{"snake", ToSnakeCase, "helloWorld", "hello_world"},
+		{"snake", ToSnakeCase, "Hello-World App", "hello_world_app"},
+		{"kebab", ToKebabCase, "HelloWorld", "hello-world"},
+		{"kebab", ToKebabCase, "hello_world", "hello-world"},
+		{"screaming", ToScreamingSnakeCase, "hello-world", "HELLO_WORLD"},
+		{"screaming", ToScreamingSnakeCase, "maxRetryCount", "MAX_RETRY_COUNT"},
+		{"dot", ToDotCase, "hello-world", "hello.world"},
+		{"path", ToPathCase, "com.example.HelloWorld", "com/example/hello/world"},
+		{"title", Title, "hello world-app", "Hello World-App"},
+		{"title", Title, "", ""},
+		{"pascal", ToPascalCase, "hello-world", "HelloWorld"},
+		{"pascal", ToPascalCase, "", ""},
+		{"camel", ToCamelCase, "hello-world", "helloWorld"},
+		{"camel", ToCamelCase, "", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.fn(tt.in); got != tt.want {
+			t.Errorf("%s(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSkipParts(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(string) string
+		in   string
+		want string
+	}{
+		{"SkipFirstPart", SkipFirstPart, "hello-world-android", "worldAndroid"},
+		{"SkipFirstPart", SkipFirstPart, "tope", "tope"},
+		{"SkipLastPart", SkipLastPart, "hello-world-android", "hello-world"},
+		{"SkipLastPart", SkipLastPart, "tope", "tope"},
+		{"SkipFirstAndLastParts", SkipFirstAndLastParts, "hello-world-golang", "world"},
+		{"SkipFirstAndLastParts", SkipFirstAndLastParts, "hello-world", "world"},
+		{"SkipFirstAndLastParts", SkipFirstAndLastParts, "hello", "hello"},
+	}
+	for _, tt := range tests {
+		if got := tt.fn(tt.in); got != tt.want {
+			t.Errorf("%s(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIndent(t *testing.T) {
+	if got, want := Indent(2, "a: 1\nb: 2"), "  a: 1\n  b: 2"; got != want {
+		t.Errorf("Indent() = %q, want %q", got, want)
+	}
+	if got, want := NIndent(4, "a\nb"), "\n    a\n    b"; got != want {
+		t.Errorf("NIndent() = %q, want %q", got, want)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	if got, want := SHA256("hello"), "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; got != want {
+		t.Errorf("SHA256() = %q, want %q", got, want)
+	}
+
+	enc := Base64Encode("hello, 世界")
+	if enc != "aGVsbG8sIOS4lueVjA==" {
+		t.Errorf("Base64Encode() = %q", enc)
+	}
+	dec, err := Base64Decode(enc)
+	if err != nil || dec != "hello, 世界" {
+		t.Errorf("Base64Decode() = %q, %v", dec, err)
+	}
+	if _, err := Base64Decode("not base64!"); err == nil {
+		t.Error("Base64Decode() of invalid input returned no error")
+	}
+}
+
+func TestRandomString(t *testing.T) {
+	s, err := RandomString(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[a-zA-Z0-9]{32}$`).MatchString(s) {
+		t.Errorf("RandomString(32) = %q", s)
+	}
+	other, _ := RandomString(32)
+	if s == other {
+		t.Errorf("RandomString returned the same value twice: %q", s)
+	}
+	if _, err := RandomString(-1); err == nil {
+		t.Error("RandomString(-1) returned no error")
+	}
+}
+
+func TestUUID(t *testing.T) {
+	u, err := UUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if !re.MatchString(u) {
+		t.Errorf("UUID() = %q is not a version 4 UUID", u)
+	}
+}
+
+func TestFuzzyFind(t *testing.T) {
+	groups := []string{"team1/backend", "team2/frontend", "team1/frontend", "infra"}
+
+	if !FuzzyMatch("tbe", "team1/backend") {
+		t.Error("FuzzyMatch(tbe, team1/backend) = false")
+	}
+	if FuzzyMatch("xyz", "team1/backend") {
+		t.Error("FuzzyMatch(xyz, team1/backend) = true")
+	}
+
+	got := FuzzyFind("front", groups)
+	want := []string{"team2/frontend", "team1/frontend"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FuzzyFind(front) = %q, want %q", got, want)
+	}
+
+	// 子串匹配排在子序列匹配之前
+	got = FuzzyFind("t1f", []string{"t1f-x", "team1/frontend"})
+	want = []string{"t1f-x", "team1/frontend"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FuzzyFind(t1f) = %q, want %q", got, want)
+	}
+}
+
+func TestStringInSlice(t *testing.T) {
+	if !StringInSlice(".go", []string{".java", ".go"}) {
+		t.Error("StringInSlice(.go) = false")
+	}
+	if StringInSlice(".ts", []string{".java", ".go"}) {
+		t.Error("StringInSlice(.ts) = true")
+	}
+}
@@ -0,0 +1,320 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package values
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Values 是模板变量的集合，可以是多层嵌套的 map 和 list
+type Values map[string]interface{}
+
+// ReadFile 读取一个 YAML 格式的变量文件
+func ReadFile(path string) (Values, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vals := Values{}
+	if err := yaml.Unmarshal(content, &vals); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return normalize(vals).(Values), nil
+}
+
+// Merge 将 src 深度合并到 dst 中，同名键以 src 为准；两边都是 map 时递归合并
+func Merge(dst, src Values) Values {
+	if dst == nil {
+		dst = Values{}
+	}
+	for k, v := range src {
+		srcMap, srcOk := v.(Values)
+		dstMap, dstOk := dst[k].(Values)
+		if srcOk && dstOk {
+			dst[k] = Merge(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// ParseSet 解析 --set 的参数并写入 vals，语法与 Helm 保持一致：
+//
+//	name=demo,db.type=mysql   多个赋值以逗号分隔，键名中的 . 表示嵌套
+//	tags={a,b,c}              花括号表示列表
+//	hosts[1]=b.example.com    方括号按下标为列表赋值
+//
+// 值中的 true/false/null 和整数会被转换为对应类型，\, \. 等可以用于转义。
+func ParseSet(vals Values, expr string) error {
+	for _, assignment := range splitUnescaped(expr, ',', true) {
+		if assignment == "" {
+			continue
+		}
+		key, value, ok := cutUnescaped(assignment, '=')
+		if !ok {
+			return fmt.Errorf("invalid --set expression %q: expected key=value", assignment)
+		}
+
+		var v interface{}
+		if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+			list := []interface{}{}
+			if inner := value[1 : len(value)-1]; inner != "" {
+				for _, item := range splitUnescaped(inner, ',', false) {
+					list = append(list, typed(unescape(item)))
+				}
+			}
+			v = list
+		} else {
+			v = typed(unescape(value))
+		}
+
+		if err := setPath(vals, key, v); err != nil {
+			return fmt.Errorf("invalid --set expression %q: %v", assignment, err)
+		}
+	}
+	return nil
+}
+
+// ParseSetFile 解析 --set-file 的参数 key=path，将文件内容作为字符串写入 vals
+func ParseSetFile(vals Values, expr string) error {
+	key, path, ok := cutUnescaped(expr, '=')
+	if !ok {
+		return fmt.Errorf("invalid --set-file expression %q: expected key=path", expr)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := setPath(vals, key, string(content)); err != nil {
+		return fmt.Errorf("invalid --set-file expression %q: %v", expr, err)
+	}
+	return nil
+}
+
+// maxIndex 是 --set 中列表下标的上限，避免 a[100000000]=x 这样的输入分配过大的列表
+const maxIndex = 65535
+
+// setPath 按照 a.b[0].c 形式的键路径写入值，必要时创建中间的 map 和 list
+func setPath(vals Values, key string, value interface{}) error {
+	segments := splitUnescaped(key, '.', false)
+	if len(segments) == 0 {
+		return fmt.Errorf("empty key")
+	}
+
+	var cur interface{} = vals
+	for i, seg := range segments {
+		name, indexes, err := parseSegment(seg)
+		if err != nil {
+			return err
+		}
+		last := i == len(segments)-1
+
+		m := cur.(Values)
+		if len(indexes) == 0 {
+			if last {
+				m[name] = value
+				return nil
+			}
+			next, ok := m[name].(Values)
+			if !ok {
+				next = Values{}
+				m[name] = next
+			}
+			cur = next
+			continue
+		}
+
+		// 处理 name[i][j] 形式的下标
+		list, _ := m[name].([]interface{})
+		holder := func(l []interface{}) { m[name] = l }
+		for j, idx := range indexes {
+			for len(list) <= idx {
+				list = append(list, nil)
+			}
+			holder(list)
+
+			if j == len(indexes)-1 {
+				if last {
+					list[idx] = value
+					return nil
+				}
+				next, ok := list[idx].(Values)
+				if !ok {
+					next = Values{}
+					list[idx] = next
+				}
+				cur = next
+				break
+			}
+
+			inner, _ := list[idx].([]interface{})
+			outer, at := list, idx
+			holder = func(l []interface{}) { outer[at] = l }
+			list = inner
+		}
+	}
+	return nil
+}
+
+// parseSegment 解析 name[0][1] 形式的键段，返回名称和下标
+func parseSegment(seg string) (string, []int, error) {
+	open := strings.IndexByte(seg, '[')
+	if open < 0 {
+		return unescape(seg), nil, nil
+	}
+
+	name := unescape(seg[:open])
+	var indexes []int
+	rest := seg[open:]
+	for rest != "" {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return "", nil, fmt.Errorf("malformed index in %q", seg)
+		}
+		idx, err := strconv.Atoi(rest[1:end])
+		if err != nil || idx < 0 {
+			return "", nil, fmt.Errorf("invalid index %q in %q", rest[1:end], seg)
+		}
+		if idx > maxIndex {
+			return "", nil, fmt.Errorf("index %d in %q exceeds the limit of %d", idx, seg, maxIndex)
+		}
+		indexes = append(indexes, idx)
+		rest = rest[end+1:]
+	}
+	return name, indexes, nil
+}
+
+// splitUnescaped 以未被转义的 sep 切分字符串，braces 为 true 时忽略花括号内部的分隔符
+func splitUnescaped(s string, sep byte, braces bool) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case braces && s[i] == '{':
+			depth++
+		case braces && s[i] == '}' && depth > 0:
+			depth--
+		case s[i] == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// cutUnescaped 在第一个未被转义的 sep 处切分字符串
+func cutUnescaped(s string, sep byte) (string, string, bool) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == sep {
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// typed 将 --set 中的字符串值转换为 bool、int 或 nil
+func typed(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && (s == "0" || !strings.HasPrefix(s, "0")) {
+		return n
+	}
+	return s
+}
+
+// normalize 将 YAML 解码得到的 map[string]interface{} 统一转换为 Values
+func normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case Values:
+		for k, item := range val {
+			val[k] = normalize(item)
+		}
+		return val
+	case map[string]interface{}:
+		return normalize(Values(val))
+	case []interface{}:
+		for i, item := range val {
+			val[i] = normalize(item)
+		}
+		return val
+	}
+	return v
+}
+
+// Options 保存命令行传入的变量来源
+type Options struct {
+	ValueFiles []string
+	Values     []string
+	FileValues []string
+}
+
+// MergeValues 按照 --values 文件（按传入顺序）、--set、--set-file 的优先级
+// 依次合并变量，后者覆盖前者
+func (o *Options) MergeValues() (Values, error) {
+	base := Values{}
+
+	for _, file := range o.ValueFiles {
+		vals, err := ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		base = Merge(base, vals)
+	}
+
+	for _, expr := range o.Values {
+		vals := Values{}
+		if err := ParseSet(vals, expr); err != nil {
+			return nil, err
+		}
+		base = Merge(base, vals)
+	}
+
+	for _, expr := range o.FileValues {
+		vals := Values{}
+		if err := ParseSetFile(vals, expr); err != nil {
+			return nil, err
+		}
+		base = Merge(base, vals)
+	}
+
+	return base, nil
+}
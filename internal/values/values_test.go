@@ -0,0 +1,70 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package values
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSet(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    Values
+		wantErr string
+	}{
+		{"name=demo", Values{"name": "demo"}, ""},
+		{"name=demo,port=8080", Values{"name": "demo", "port": 8080}, ""},
+		{"enabled=true,debug=false,extra=null", Values{"enabled": true, "debug": false, "extra": nil}, ""},
+		{"zip=007,zero=0", Values{"zip": "007", "zero": 0}, ""},
+		{"db.type=mysql,db.port=3306", Values{"db": Values{"type": "mysql", "port": 3306}}, ""},
+		{"tags={a,b,c}", Values{"tags": []interface{}{"a", "b", "c"}}, ""},
+		{"tags={}", Values{"tags": []interface{}{}}, ""},
+		{"hosts[1]=b.example.com", Values{"hosts": []interface{}{nil, "b.example.com"}}, ""},
+		{"hosts[0].name=a,hosts[0].port=80", Values{"hosts": []interface{}{Values{"name": "a", "port": 80}}}, ""},
+		{"matrix[1][0]=x", Values{"matrix": []interface{}{nil, []interface{}{"x"}}}, ""},
+		{`url=a\,b,domain=example\.com`, Values{"url": "a,b", "domain": "example.com"}, ""},
+		{`a\.b=c`, Values{"a.b": "c"}, ""},
+		{"empty=", Values{"empty": ""}, ""},
+		{"name", nil, "expected key=value"},
+		{"a[x]=1", nil, `invalid index "x"`},
+		{"a[-1]=1", nil, `invalid index "-1"`},
+		{"a[1=1", nil, "malformed index"},
+		{"a[65535]=1", Values{"a": make([]interface{}, 65536)}, ""},
+		{"a[100000000]=x", nil, "exceeds the limit of 65535"},
+	}
+	for _, tt := range tests {
+		vals := Values{}
+		err := ParseSet(vals, tt.expr)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseSet(%q) error = %v, want an error containing %q", tt.expr, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSet(%q) unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if tt.expr == "a[65535]=1" {
+			tt.want["a"].([]interface{})[65535] = 1
+		}
+		if !reflect.DeepEqual(vals, tt.want) {
+			t.Errorf("ParseSet(%q) = %v, want %v", tt.expr, vals, tt.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dst := Values{"db": Values{"type": "mysql", "port": 3306}, "tags": []interface{}{"a"}}
+	src := Values{"db": Values{"type": "postgres"}, "tags": []interface{}{"b"}}
+
+	want := Values{"db": Values{"type": "postgres", "port": 3306}, "tags": []interface{}{"b"}}
+	if got := Merge(dst, src); !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package workflow
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/imxw/gitlab-scaffold/internal/stringx"
+)
+
+// Journal 是保存在本地的操作日志，按目标项目记录已完成的步骤，
+// 用于在中断后通过 glfast use --resume 继续创建项目。
+// 日志在流程成功或全部撤销后删除。
+type Journal struct {
+	path string
+
+	Project   string    `json:"project"`
+	Template  string    `json:"template"`
+	SHA       string    `json:"sha"`
+	StartedAt time.Time `json:"started_at"`
+	Steps     []string  `json:"steps"`
+}
+
+// journalDir 返回日志目录，默认为用户缓存目录下的 glfast/journal
+func journalDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "glfast", "journal"), nil
+}
+
+// OpenJournal 读取目标项目的日志，日志不存在时返回一个新日志和 false
+func OpenJournal(project string) (*Journal, bool, error) {
+	dir, err := journalDir()
+	if err != nil {
+		return nil, false, err
+	}
+
+	j := &Journal{
+		path:      filepath.Join(dir, url.PathEscape(project)+".json"),
+		Project:   project,
+		StartedAt: time.Now(),
+	}
+
+	content, err := os.ReadFile(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return j, false, nil
+		}
+		return nil, false, err
+	}
+
+	if err := json.Unmarshal(content, j); err != nil {
+		return nil, false, err
+	}
+	return j, true, nil
+}
+
+// Done 判断日志中是否记录了步骤已完成
+func (j *Journal) Done(step string) bool {
+	return stringx.StringInSlice(step, j.Steps)
+}
+
+// MarkDone 记录步骤已完成并写入文件
+func (j *Journal) MarkDone(step string) error {
+	if !j.Done(step) {
+		j.Steps = append(j.Steps, step)
+	}
+	return j.Save()
+}
+
+// MarkUndone 记录步骤已撤销并写入文件
+func (j *Journal) MarkUndone(step string) error {
+	steps := j.Steps[:0]
+	for _, s := range j.Steps {
+		if s != step {
+			steps = append(steps, s)
+		}
+	}
+	j.Steps = steps
+	return j.Save()
+}
+
+// Save 把日志写入文件
+func (j *Journal) Save() error {
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(j.path, content, 0644)
+}
+
+// Remove 删除日志文件
+func (j *Journal) Remove() error {
+	err := os.Remove(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Path 返回日志文件的路径
+func (j *Journal) Path() string {
+	return j.path
+}
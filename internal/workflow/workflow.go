@@ -0,0 +1,155 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Step 是创建流程中的一个步骤。Undo 撤销 Do 的效果，为 nil 表示该步骤无需撤销。
+// Check 检查步骤的效果是否已经存在于 GitLab 中，用于继续被中断的流程，为 nil 时以日志为准。
+// Do 和 Check 收到的 ctx 被取消时应尽快返回，Undo 收到的 ctx 不会因为流程被中断而取消。
+type Step struct {
+	Name  string
+	Do    func(ctx context.Context) error
+	Undo  func(ctx context.Context) error
+	Check func(ctx context.Context) (bool, error)
+	// Partial 为 true 时 Do 失败后也会调用 Undo，撤销 Do 已经完成的部分，
+	// 用于逐项执行的步骤，Undo 只能撤销 Do 实际完成的项
+	Partial bool
+}
+
+// Workflow 按顺序执行一组步骤，某一步失败或 ctx 被取消时按相反顺序撤销已完成的步骤
+type Workflow struct {
+	steps []Step
+	out   io.Writer
+
+	// KeepOnFailure 为 true 时失败后不撤销已完成的步骤，便于排查问题
+	KeepOnFailure bool
+	// Resume 为 true 时跳过已经完成的步骤
+	Resume bool
+	// Journal 不为 nil 时记录每个步骤的进度
+	Journal *Journal
+}
+
+// New 创建一个把进度输出到 out 的 Workflow
+func New(out io.Writer) *Workflow {
+	return &Workflow{out: out}
+}
+
+// Add 在流程末尾追加步骤
+func (w *Workflow) Add(steps ...Step) {
+	w.steps = append(w.steps, steps...)
+}
+
+// Run 依次执行所有步骤。ctx 被取消（例如收到 SIGINT 或超时）时正在执行的步骤会中止，
+// 并且不再执行后续步骤。
+// 失败时返回的错误包含失败的步骤，撤销过程中的错误会一并返回。
+// 继续执行时跳过的步骤不属于本次运行，失败时不会被撤销。
+func (w *Workflow) Run(ctx context.Context) error {
+	var done []Step
+	for _, step := range w.steps {
+		err := ctx.Err()
+		if err == nil && w.Resume {
+			var skip bool
+			if skip, err = w.isDone(ctx, step); err == nil && skip {
+				fmt.Fprintf(w.out, "--- %s (already done)\n", step.Name)
+				continue
+			}
+		}
+		ran := false
+		if err == nil {
+			fmt.Fprintf(w.out, "==> %s\n", step.Name)
+			err = step.Do(ctx)
+			ran = true
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %w", step.Name, err)
+			if step.Partial && ran {
+				done = append(done, step)
+			}
+			return errors.Join(err, w.rollback(done))
+		}
+		done = append(done, step)
+		if err := w.record(step.Name, true); err != nil {
+			return errors.Join(err, w.rollback(done))
+		}
+	}
+
+	if w.Journal != nil {
+		return w.Journal.Remove()
+	}
+	return nil
+}
+
+// isDone 判断步骤是否已经完成，优先以 GitLab 中的实际状态为准
+func (w *Workflow) isDone(ctx context.Context, step Step) (bool, error) {
+	if step.Check != nil {
+		return step.Check(ctx)
+	}
+	return w.Journal != nil && w.Journal.Done(step.Name), nil
+}
+
+// record 在日志中记录步骤完成或撤销
+func (w *Workflow) record(step string, done bool) error {
+	if w.Journal == nil {
+		return nil
+	}
+	if done {
+		return w.Journal.MarkDone(step)
+	}
+	return w.Journal.MarkUndone(step)
+}
+
+// rollback 按相反顺序撤销已完成的步骤，单个步骤撤销失败不影响其他步骤。
+// 全部撤销成功后删除日志，否则保留日志以便继续执行。
+// 流程被取消后仍然需要撤销，所以撤销时不使用 Run 的 ctx。
+func (w *Workflow) rollback(done []Step) error {
+	if w.KeepOnFailure {
+		fmt.Fprintln(w.out, "Keeping completed steps for debugging (--keep-on-failure)")
+		w.printResumeHint()
+		return nil
+	}
+	if len(done) == 0 {
+		w.printResumeHint()
+		return nil
+	}
+
+	fmt.Fprintln(w.out, "Rolling back...")
+	var errs []error
+	for i := len(done) - 1; i >= 0; i-- {
+		step := done[i]
+		if step.Undo != nil {
+			fmt.Fprintf(w.out, "<== undo %s\n", step.Name)
+			if err := step.Undo(context.Background()); err != nil {
+				errs = append(errs, fmt.Errorf("undo %s: %w", step.Name, err))
+				continue
+			}
+		}
+		if err := w.record(step.Name, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		w.printResumeHint()
+		return errors.Join(errs...)
+	}
+	if w.Journal != nil && len(w.Journal.Steps) == 0 {
+		return w.Journal.Remove()
+	}
+	w.printResumeHint()
+	return nil
+}
+
+func (w *Workflow) printResumeHint() {
+	if w.Journal != nil && len(w.Journal.Steps) > 0 {
+		fmt.Fprintf(w.out, "Progress is recorded in %s, run again with --resume to finish.\n", w.Journal.Path())
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright © 2023 Xu Wu <ixw1991@126.com>
+Use of this source code is governed by a MIT style
+license that can be found in the LICENSE file.
+*/
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// recorder 记录步骤的执行和撤销顺序
+type recorder struct {
+	calls []string
+}
+
+// step 返回一个步骤，fail 不为 nil 时 Do 返回该错误，done 不为 nil 时用作 Check
+func (r *recorder) step(name string, fail error, done *bool) Step {
+	s := Step{
+		Name: name,
+		Do: func(ctx context.Context) error {
+			r.calls = append(r.calls, "do "+name)
+			return fail
+		},
+		Undo: func(ctx context.Context) error {
+			r.calls = append(r.calls, "undo "+name)
+			return nil
+		},
+	}
+	if done != nil {
+		s.Check = func(ctx context.Context) (bool, error) { return *done, nil }
+	}
+	return s
+}
+
+// useTempCache 把日志目录指向临时目录
+func useTempCache(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("LocalAppData", dir)
+}
+
+func TestRun(t *testing.T) {
+	errFailed := errors.New("failed")
+	yes := true
+
+	tests := []struct {
+		name    string
+		steps   func(r *recorder) []Step
+		keep    bool
+		resume  bool
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "success",
+			steps: func(r *recorder) []Step {
+				return []Step{r.step("a", nil, nil), r.step("b", nil, nil)}
+			},
+			want: []string{"do a", "do b"},
+		},
+		{
+			name: "rollback in reverse order",
+			steps: func(r *recorder) []Step {
+				return []Step{r.step("a", nil, nil), r.step("b", nil, nil), r.step("c", errFailed, nil)}
+			},
+			want:    []string{"do a", "do b", "do c", "undo b", "undo a"},
+			wantErr: true,
+		},
+		{
+			name: "partial step is undone",
+			steps: func(r *recorder) []Step {
+				s := r.step("b", errFailed, nil)
+				s.Partial = true
+				return []Step{r.step("a", nil, nil), s}
+			},
+			want:    []string{"do a", "do b", "undo b", "undo a"},
+			wantErr: true,
+		},
+		{
+			name: "keep on failure",
+			steps: func(r *recorder) []Step {
+				return []Step{r.step("a", nil, nil), r.step("b", errFailed, nil)}
+			},
+			keep:    true,
+			want:    []string{"do a", "do b"},
+			wantErr: true,
+		},
+		{
+			name: "resume skips steps already done",
+			steps: func(r *recorder) []Step {
+				return []Step{r.step("a", nil, &yes), r.step("b", nil, nil)}
+			},
+			resume: true,
+			want:   []string{"do b"},
+		},
+		{
+			name: "skipped steps are not undone",
+			steps: func(r *recorder) []Step {
+				return []Step{r.step("a", nil, &yes), r.step("b", nil, nil), r.step("c", errFailed, nil)}
+			},
+			resume:  true,
+			want:    []string{"do b", "do c", "undo b"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		r := &recorder{}
+		w := New(&bytes.Buffer{})
+		w.KeepOnFailure = tt.keep
+		w.Resume = tt.resume
+		w.Add(tt.steps(r)...)
+
+		err := w.Run(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Run() error = %v, wantErr %t", tt.name, err, tt.wantErr)
+		}
+		if tt.wantErr && !errors.Is(err, errFailed) {
+			t.Errorf("%s: Run() error = %v, want it to wrap %v", tt.name, err, errFailed)
+		}
+		if !reflect.DeepEqual(r.calls, tt.want) {
+			t.Errorf("%s: calls = %q, want %q", tt.name, r.calls, tt.want)
+		}
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	r := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := New(&bytes.Buffer{})
+	w.Add(r.step("a", nil, nil), Step{
+		Name: "cancel",
+		Do: func(ctx context.Context) error {
+			cancel()
+			return nil
+		},
+	}, r.step("b", nil, nil))
+
+	if err := w.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want context.Canceled", err)
+	}
+	if want := []string{"do a", "undo a"}; !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("calls = %q, want %q", r.calls, want)
+	}
+}
+
+func TestJournalResume(t *testing.T) {
+	useTempCache(t)
+	errFailed := errors.New("failed")
+
+	// 第一次运行在 c 失败并保留已完成的步骤，日志记录 a 和 b
+	j, found, err := OpenJournal("team/app")
+	if err != nil || found {
+		t.Fatalf("OpenJournal() = %t, %v, want a new journal", found, err)
+	}
+	j.Template, j.SHA = "backend-java", "abc123"
+
+	r := &recorder{}
+	w := New(&bytes.Buffer{})
+	w.KeepOnFailure = true
+	w.Journal = j
+	w.Add(r.step("a", nil, nil), r.step("b", nil, nil), r.step("c", errFailed, nil))
+	if err := w.Run(context.Background()); !errors.Is(err, errFailed) {
+		t.Fatalf("first Run() error = %v, want %v", err, errFailed)
+	}
+
+	j, found, err = OpenJournal("team/app")
+	if err != nil || !found {
+		t.Fatalf("OpenJournal() = %t, %v, want the saved journal", found, err)
+	}
+	if j.Template != "backend-java" || j.SHA != "abc123" || !reflect.DeepEqual(j.Steps, []string{"a", "b"}) {
+		t.Fatalf("journal = %+v, want template backend-java@abc123 with steps a, b", j)
+	}
+
+	// 继续执行时按日志跳过 a 和 b，成功后删除日志
+	r = &recorder{}
+	w = New(&bytes.Buffer{})
+	w.Resume = true
+	w.Journal = j
+	w.Add(r.step("a", nil, nil), r.step("b", nil, nil), r.step("c", nil, nil))
+	if err := w.Run(context.Background()); err != nil {
+		t.Fatalf("resumed Run() error = %v", err)
+	}
+	if want := []string{"do c"}; !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("calls = %q, want %q", r.calls, want)
+	}
+	if _, err := os.Stat(j.Path()); !os.IsNotExist(err) {
+		t.Errorf("journal %s still exists after success", j.Path())
+	}
+}
+
+func TestJournalRemovedAfterRollback(t *testing.T) {
+	useTempCache(t)
+
+	j, _, err := OpenJournal("team/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &recorder{}
+	w := New(&bytes.Buffer{})
+	w.Journal = j
+	w.Add(r.step("a", nil, nil), r.step("b", errors.New("failed"), nil))
+	if err := w.Run(context.Background()); err == nil {
+		t.Fatal("Run() succeeded, want an error")
+	}
+	if _, err := os.Stat(j.Path()); !os.IsNotExist(err) {
+		t.Errorf("journal %s still exists after a full rollback", j.Path())
+	}
+}